@@ -0,0 +1,50 @@
+package hooks
+
+// AddAction adds a callback to an action.
+func (e *Engine) AddAction(hookName string, namespace string, callback func(...interface{}) interface{}, priority int) {
+	e.addHook(e.actions, hookName, namespace, callback, priority)
+}
+
+// AddFilter adds a callback to a filter.
+func (e *Engine) AddFilter(hookName string, namespace string, callback func(...interface{}) interface{}, priority int) {
+	e.addHook(e.filters, hookName, namespace, callback, priority)
+}
+
+// addHook adds a hook.
+func (e *Engine) addHook(hooks *Hooks, hookName string, namespace string, callback func(...interface{}) interface{}, priority int) {
+	e.addHandler(hooks, hookName, Handler{
+		Namespace: namespace,
+		Callback:  callback,
+		Priority:  priority,
+	})
+}
+
+// addHandler inserts a handler into the list of the given hook according to
+// its priority, then triggers the HookAdded action. It returns the id given
+// to the handler.
+//
+// When the handler would make the ordering constraints of the callbacks of
+// the hook contradict each other, it is not added: the HookFailed action is
+// triggered instead, passing an error wrapping ErrOrderCycle, and 0 is
+// returned.
+func (e *Engine) addHandler(hooks *Hooks, hookName string, handler Handler) uint64 {
+	handler.Owner = e.owner
+	handler.source = callerSource()
+
+	hooks.mu.Lock()
+	hooks.nextID++
+	handler.id = hooks.nextID
+	err := hooks.insertHandler(hookName, handler)
+	hooks.mu.Unlock()
+
+	if err != nil {
+		e.DoAction("HookFailed", hookName, handler.Namespace, handler.Priority, err)
+		return 0
+	}
+
+	if hookName != "HookAdded" {
+		e.DoAction("HookAdded", hookName, handler.Namespace, handler.callback(), handler.Priority)
+	}
+
+	return handler.id
+}
@@ -0,0 +1,25 @@
+package hooks
+
+import "context"
+
+// AddActionContext adds a callback to an action, which receives the context
+// passed to DoActionContext.
+func (e *Engine) AddActionContext(hookName string, namespace string, callback func(context.Context, ...interface{}) interface{}, priority int) {
+	e.addHookContext(e.actions, hookName, namespace, callback, priority)
+}
+
+// AddFilterContext adds a callback to a filter, which receives the context
+// passed to ApplyFiltersContext.
+func (e *Engine) AddFilterContext(hookName string, namespace string, callback func(context.Context, ...interface{}) interface{}, priority int) {
+	e.addHookContext(e.filters, hookName, namespace, callback, priority)
+}
+
+// addHookContext adds a hook whose callback receives the context passed to
+// DoActionContext or ApplyFiltersContext.
+func (e *Engine) addHookContext(hooks *Hooks, hookName string, namespace string, callback func(context.Context, ...interface{}) interface{}, priority int) {
+	e.addHandler(hooks, hookName, Handler{
+		Namespace:       namespace,
+		ContextCallback: callback,
+		Priority:        priority,
+	})
+}
@@ -0,0 +1,24 @@
+package hooks
+
+import "context"
+
+// AddActionE adds a callback to an action, which can fail by returning an
+// error.
+func (e *Engine) AddActionE(hookName string, namespace string, callback func(context.Context, ...interface{}) (interface{}, error), priority int) {
+	e.addHookE(e.actions, hookName, namespace, callback, priority)
+}
+
+// AddFilterE adds a callback to a filter, which can fail by returning an
+// error.
+func (e *Engine) AddFilterE(hookName string, namespace string, callback func(context.Context, ...interface{}) (interface{}, error), priority int) {
+	e.addHookE(e.filters, hookName, namespace, callback, priority)
+}
+
+// addHookE adds a hook whose callback can fail by returning an error.
+func (e *Engine) addHookE(hooks *Hooks, hookName string, namespace string, callback func(context.Context, ...interface{}) (interface{}, error), priority int) {
+	e.addHandler(hooks, hookName, Handler{
+		Namespace:     namespace,
+		ErrorCallback: callback,
+		Priority:      priority,
+	})
+}
@@ -0,0 +1,46 @@
+package hooks
+
+// AddActionOrdered adds a callback to an action, which runs before or after
+// the callbacks of other namespaces as given by order.
+func (e *Engine) AddActionOrdered(hookName string, namespace string, callback func(...interface{}) interface{}, priority int, order Order) error {
+	return e.addHookOrdered(e.actions, hookName, namespace, callback, priority, order)
+}
+
+// AddFilterOrdered adds a callback to a filter, which runs before or after
+// the callbacks of other namespaces as given by order.
+func (e *Engine) AddFilterOrdered(hookName string, namespace string, callback func(...interface{}) interface{}, priority int, order Order) error {
+	return e.addHookOrdered(e.filters, hookName, namespace, callback, priority, order)
+}
+
+// addHookOrdered adds a hook whose callback runs before or after the
+// callbacks of other namespaces as given by an Order. The callback is not
+// added, and an error wrapping ErrOrderCycle is returned, if the constraints
+// cannot be satisfied.
+func (e *Engine) addHookOrdered(hooks *Hooks, hookName string, namespace string, callback func(...interface{}) interface{}, priority int, order Order) error {
+	handler := Handler{
+		Namespace: namespace,
+		Callback:  callback,
+		Priority:  priority,
+		Order:     order,
+		Owner:     e.owner,
+		source:    callerSource(),
+	}
+
+	hooks.mu.Lock()
+
+	hooks.nextID++
+	handler.id = hooks.nextID
+
+	if err := hooks.insertHandler(hookName, handler); err != nil {
+		hooks.mu.Unlock()
+		return err
+	}
+
+	hooks.mu.Unlock()
+
+	if hookName != "HookAdded" {
+		e.DoAction("HookAdded", hookName, namespace, callback, priority)
+	}
+
+	return nil
+}
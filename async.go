@@ -0,0 +1,186 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+)
+
+var (
+	// ErrQueueFull is returned by DoActionAsync when the queue of the pool is
+	// full and its Backpressure is ErrorWhenFull. It is also passed to
+	// OnError for actions dropped by DropNewest and DropOldest.
+	ErrQueueFull = errors.New("async queue is full")
+	// ErrPoolClosed is returned by DoActionAsync once the pool is draining or
+	// closed.
+	ErrPoolClosed = errors.New("async pool is closed")
+)
+
+// Backpressure decides what DoActionAsync does when the queue of an AsyncPool
+// is full.
+type Backpressure int
+
+const (
+	// BlockWhenFull waits for room in the queue. It is the default.
+	BlockWhenFull Backpressure = iota
+	// DropNewest drops the action being enqueued.
+	DropNewest
+	// DropOldest drops the oldest queued action to make room.
+	DropOldest
+	// ErrorWhenFull returns ErrQueueFull.
+	ErrorWhenFull
+)
+
+// AsyncOptions configures an AsyncPool.
+type AsyncOptions struct {
+	// Workers is the number of goroutines running actions. It defaults to
+	// runtime.NumCPU().
+	Workers int
+	// QueueSize is the number of actions that can wait for a worker. It
+	// defaults to 1024.
+	QueueSize int
+	// Backpressure applies when the queue is full.
+	Backpressure Backpressure
+	// OnError, when set, receives the errors returned by the actions run by
+	// the pool, as well as ErrQueueFull for the actions it dropped.
+	OnError func(hookName string, err error)
+}
+
+// AsyncPool runs actions of a Dispatcher in the background on a fixed number of
+// workers. Actions run with DoActionContext, so callbacks and policies are the
+// same as for synchronous actions; with the default PanicPolicy a panicking
+// callback crashes the program as in any goroutine.
+type AsyncPool struct {
+	hooks   Dispatcher
+	options AsyncOptions
+	queue   chan asyncAction
+
+	// mu is held for reading while enqueueing, and for writing to close the
+	// queue once nothing can be enqueued anymore.
+	mu      sync.RWMutex
+	closed  bool
+	closing chan struct{}
+	once    sync.Once
+	workers sync.WaitGroup
+}
+
+type asyncAction struct {
+	hookName string
+	args     []interface{}
+}
+
+// NewAsyncPool starts the workers of a pool running the actions of hooks.
+func NewAsyncPool(hooks Dispatcher, options AsyncOptions) *AsyncPool {
+	if options.Workers <= 0 {
+		options.Workers = runtime.NumCPU()
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = 1024
+	}
+
+	p := &AsyncPool{
+		hooks:   hooks,
+		options: options,
+		queue:   make(chan asyncAction, options.QueueSize),
+		closing: make(chan struct{}),
+	}
+
+	p.workers.Add(options.Workers)
+	for i := 0; i < options.Workers; i++ {
+		go p.work()
+	}
+
+	return p
+}
+
+// DoActionAsync enqueues an action to be run by a worker and returns without
+// waiting for it.
+func (p *AsyncPool) DoActionAsync(hookName string, args ...interface{}) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	action := asyncAction{hookName: hookName, args: args}
+
+	for {
+		select {
+		case p.queue <- action:
+			return nil
+		default:
+		}
+
+		switch p.options.Backpressure {
+		case DropNewest:
+			p.report(hookName, ErrQueueFull)
+			return nil
+		case DropOldest:
+			select {
+			case oldest := <-p.queue:
+				p.report(oldest.hookName, ErrQueueFull)
+			default:
+			}
+		case ErrorWhenFull:
+			return ErrQueueFull
+		default:
+			select {
+			case p.queue <- action:
+				return nil
+			case <-p.closing:
+				return ErrPoolClosed
+			}
+		}
+	}
+}
+
+// Drain stops accepting actions and waits until the queued actions have run
+// and the workers have exited, or until ctx is done.
+func (p *AsyncPool) Drain(ctx context.Context) error {
+	p.once.Do(func() {
+		// Wake up the callers blocked on a full queue, then wait for every
+		// caller to leave DoActionAsync before closing the queue.
+		close(p.closing)
+
+		p.mu.Lock()
+		p.closed = true
+		close(p.queue)
+		p.mu.Unlock()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting actions and waits until the queued actions have run.
+func (p *AsyncPool) Close() error {
+	return p.Drain(context.Background())
+}
+
+func (p *AsyncPool) work() {
+	defer p.workers.Done()
+
+	for action := range p.queue {
+		if err := p.hooks.DoActionContext(context.Background(), action.hookName, action.args...); err != nil {
+			p.report(action.hookName, err)
+		}
+	}
+}
+
+func (p *AsyncPool) report(hookName string, err error) {
+	if p.options.OnError != nil {
+		p.options.OnError(hookName, err)
+	}
+}
@@ -0,0 +1,302 @@
+package hooks
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BreakerOptions configures the circuit breakers of a hook, which skip the
+// callbacks of a namespace failing repeatedly.
+type BreakerOptions struct {
+	// Failures is the number of failures within Window opening the circuit.
+	Failures int
+	// Window is the time failures are counted over. Zero counts every
+	// failure since the circuit last closed.
+	Window time.Duration
+	// Cooldown is the time the circuit stays open before a single call is
+	// let through to test the callbacks again.
+	Cooldown time.Duration
+}
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through. It is the initial state.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen skips the callbacks until the cooldown is over.
+	BreakerOpen
+	// BreakerHalfOpen lets a single call through: the circuit closes if it
+	// succeeds and opens again if it fails.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// BreakerInfo describes the circuit breaker of the callbacks of a namespace
+// for a hook.
+type BreakerInfo struct {
+	Hook      string
+	Namespace string
+	State     BreakerState
+	// Failures is the number of failures currently counted.
+	Failures int
+}
+
+type breakerKey struct {
+	hookName  string
+	namespace string
+}
+
+type breaker struct {
+	state    BreakerState
+	failures []time.Time
+	openedAt time.Time
+	// trial is set while the call let through by a half-open circuit runs.
+	trial bool
+}
+
+// breakers holds the circuit breakers of a registry. It has its own lock, so
+// that runs can update the breakers without holding hooks.mu.
+type breakers struct {
+	mu sync.Mutex
+	// options holds the BreakerOptions of each hook, the entry for the empty
+	// name being the default.
+	options map[string]BreakerOptions
+	states  map[breakerKey]*breaker
+	// enabled is set while options has entries, so that runs do not take
+	// the lock when no hook has circuit breakers.
+	enabled atomic.Bool
+}
+
+func newBreakers() *breakers {
+	return &breakers{
+		options: map[string]BreakerOptions{},
+		states:  map[breakerKey]*breaker{},
+	}
+}
+
+// breakerChange is a transition of a circuit breaker, reported by the
+// BreakerStateChanged action.
+type breakerChange struct {
+	key      breakerKey
+	from, to BreakerState
+}
+
+// SetActionBreaker sets the BreakerOptions of an action.
+func (e *Engine) SetActionBreaker(hookName string, options BreakerOptions) {
+	setBreaker(e.actions, hookName, options)
+}
+
+// SetFilterBreaker sets the BreakerOptions of a filter.
+func (e *Engine) SetFilterBreaker(hookName string, options BreakerOptions) {
+	setBreaker(e.filters, hookName, options)
+}
+
+// setBreaker sets the BreakerOptions of a hook. An empty hook name sets the
+// options of every hook without its own, and zero options remove them,
+// closing the circuits of the hook.
+func setBreaker(hooks *Hooks, hookName string, options BreakerOptions) {
+	b := hooks.breakers
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if options == (BreakerOptions{}) {
+		delete(b.options, hookName)
+	} else {
+		b.options[hookName] = options
+	}
+	b.enabled.Store(len(b.options) > 0)
+
+	for key := range b.states {
+		if key.hookName == hookName || hookName == "" {
+			delete(b.states, key)
+		}
+	}
+}
+
+// ActionBreakers returns the circuit breakers of the actions which are not
+// closed or have failures counted.
+func (e *Engine) ActionBreakers() []BreakerInfo {
+	return breakersOf(e.actions)
+}
+
+// FilterBreakers returns the circuit breakers of the filters which are not
+// closed or have failures counted.
+func (e *Engine) FilterBreakers() []BreakerInfo {
+	return breakersOf(e.filters)
+}
+
+// breakersOf returns the circuit breakers of a registry which are not closed
+// or have failures counted, sorted by hook name and namespace.
+func breakersOf(hooks *Hooks) []BreakerInfo {
+	b := hooks.breakers
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	infos := []BreakerInfo{}
+	for key, state := range b.states {
+		b.prune(key, state, now)
+		if state.state == BreakerClosed && len(state.failures) == 0 {
+			delete(b.states, key)
+			continue
+		}
+
+		infos = append(infos, BreakerInfo{
+			Hook:      key.hookName,
+			Namespace: key.namespace,
+			State:     state.state,
+			Failures:  len(state.failures),
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Hook != infos[j].Hook {
+			return infos[i].Hook < infos[j].Hook
+		}
+		return infos[i].Namespace < infos[j].Namespace
+	})
+
+	return infos
+}
+
+// optionsOf returns the BreakerOptions of a hook. It must be called with b.mu
+// held.
+func (b *breakers) optionsOf(hookName string) (BreakerOptions, bool) {
+	if options, ok := b.options[hookName]; ok {
+		return options, true
+	}
+	options, ok := b.options[""]
+	return options, ok
+}
+
+// prune forgets the failures which fell out of the window. It must be called
+// with b.mu held.
+func (b *breakers) prune(key breakerKey, state *breaker, now time.Time) {
+	options, _ := b.optionsOf(key.hookName)
+	if options.Window <= 0 {
+		return
+	}
+
+	i := 0
+	for i < len(state.failures) && now.Sub(state.failures[i]) > options.Window {
+		i++
+	}
+	state.failures = state.failures[i:]
+}
+
+// allow returns whether the callbacks of a namespace may run for a hook, and
+// the transition to half-open it caused, if any.
+func (b *breakers) allow(hookName string, namespace string) (bool, *breakerChange) {
+	if !b.enabled.Load() {
+		return true, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	options, ok := b.optionsOf(hookName)
+	if !ok {
+		return true, nil
+	}
+
+	key := breakerKey{hookName, namespace}
+	state := b.states[key]
+	if state == nil {
+		return true, nil
+	}
+
+	switch state.state {
+	case BreakerOpen:
+		if time.Since(state.openedAt) < options.Cooldown {
+			return false, nil
+		}
+		state.state = BreakerHalfOpen
+		state.trial = true
+		return true, &breakerChange{key, BreakerOpen, BreakerHalfOpen}
+	case BreakerHalfOpen:
+		if state.trial {
+			return false, nil
+		}
+		state.trial = true
+	}
+
+	return true, nil
+}
+
+// record records the outcome of a call of the callbacks of a namespace for a
+// hook, and returns the transition it caused, if any.
+func (b *breakers) record(hookName string, namespace string, failed bool) *breakerChange {
+	if !b.enabled.Load() {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	options, ok := b.optionsOf(hookName)
+	if !ok {
+		return nil
+	}
+
+	key := breakerKey{hookName, namespace}
+	state := b.states[key]
+	if state == nil {
+		if !failed {
+			return nil
+		}
+		state = &breaker{}
+		b.states[key] = state
+	}
+
+	now := time.Now()
+
+	if state.state == BreakerHalfOpen {
+		state.trial = false
+
+		if failed {
+			state.state = BreakerOpen
+			state.openedAt = now
+			return &breakerChange{key, BreakerHalfOpen, BreakerOpen}
+		}
+
+		delete(b.states, key)
+		return &breakerChange{key, BreakerHalfOpen, BreakerClosed}
+	}
+
+	if !failed || state.state == BreakerOpen {
+		return nil
+	}
+
+	state.failures = append(state.failures, now)
+	b.prune(key, state, now)
+
+	if len(state.failures) < options.Failures {
+		return nil
+	}
+
+	state.state = BreakerOpen
+	state.openedAt = now
+	state.failures = nil
+	return &breakerChange{key, BreakerClosed, BreakerOpen}
+}
+
+// report triggers the BreakerStateChanged action for a transition, passing
+// values for the hook name, the namespace, the previous state and the new
+// state.
+func (change *breakerChange) report(e *Engine) {
+	if change != nil && change.key.hookName != "BreakerStateChanged" {
+		e.DoAction("BreakerStateChanged", change.key.hookName, change.key.namespace, change.from, change.to)
+	}
+}
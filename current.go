@@ -0,0 +1,28 @@
+package hooks
+
+import "errors"
+
+// CurrentAction returns the HookInfo of the innermost action running in the
+// calling goroutine.
+func (e *Engine) CurrentAction() (HookInfo, error) {
+	return currentHook(e.actions)
+}
+
+// CurrentFilter returns the HookInfo of the innermost filter running in the
+// calling goroutine.
+func (e *Engine) CurrentFilter() (HookInfo, error) {
+	return currentHook(e.filters)
+}
+
+// currentHook returns the HookInfo of the currently running hook or an error
+// if no hook is currently running.
+func currentHook(hooks *Hooks) (HookInfo, error) {
+	stack := hooks.stack()
+
+	i := hooks.innermost(stack)
+	if i < 0 {
+		return HookInfo{}, errors.New("no currently running hook")
+	}
+
+	return stackInfo(stack, i), nil
+}
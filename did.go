@@ -0,0 +1,19 @@
+package hooks
+
+// DidAction returns the number of times an action has been run.
+func (e *Engine) DidAction(hookName string) int {
+	return didHook(e.actions, hookName)
+}
+
+// DidFilter returns the number of times a filter has been run.
+func (e *Engine) DidFilter(hookName string) int {
+	return didHook(e.filters, hookName)
+}
+
+// didHook returns the number of times a hook has been called.
+func didHook(hooks *Hooks, hookName string) int {
+	if state := hooks.lookup(hookName); state != nil {
+		return int(state.runs.Load())
+	}
+	return 0
+}
@@ -0,0 +1,187 @@
+package hooks
+
+import "sync/atomic"
+
+// dispatch holds the state of a registry read by running hooks, which never
+// lock the registry. Writers hold hooks.mu and replace what they change
+// instead of modifying it.
+type dispatch struct {
+	// states holds the state of each hook, keyed by hook name. It has an
+	// entry for every entry of Hooks.Hooks, and is guarded by hooks.mu.
+	states map[string]*hookState
+	// read holds a copy of states which is read without locking. It may lack
+	// the latest entries, and is copied again once it missed as many lookups
+	// as states has entries.
+	read   atomic.Pointer[map[string]*hookState]
+	misses atomic.Int64
+	// group is given to the running hooks of the registry. It is shared by
+	// the actions and the filters of a Core.
+	group *runGroup
+	// config holds the settings of the hooks.
+	config atomic.Pointer[hookConfig]
+	// observers holds the state of the "all" hook once it has an entry.
+	observers atomic.Pointer[hookState]
+}
+
+func newDispatch(group *runGroup) *dispatch {
+	d := &dispatch{group: group, states: map[string]*hookState{}}
+	d.read.Store(&map[string]*hookState{})
+	d.config.Store(&hookConfig{
+		errorPolicies: map[string]ErrorPolicy{},
+		panicPolicies: map[string]PanicPolicy{},
+		parallel:      map[string]int{},
+		timeouts:      map[string]Timeouts{},
+	})
+	return d
+}
+
+// hookState is the state of a hook read by its runs.
+type hookState struct {
+	// handlers is a snapshot of the handlers of the hook, which is replaced
+	// and never modified.
+	handlers atomic.Pointer[[]Handler]
+	runs     atomic.Int64
+}
+
+// hookConfig holds the settings of the hooks of a registry. It is replaced
+// and never modified.
+type hookConfig struct {
+	// errorPolicies holds the ErrorPolicy of each hook, the entry for the
+	// empty name being the default.
+	errorPolicies map[string]ErrorPolicy
+
+	// panicPolicies holds the PanicPolicy of each hook, the entry for the
+	// empty name being the default.
+	panicPolicies map[string]PanicPolicy
+
+	// parallel holds the concurrency of the actions running their callbacks
+	// concurrently.
+	parallel map[string]int
+
+	// timeouts holds the Timeouts of each hook, the entry for the empty name
+	// being the default.
+	timeouts map[string]Timeouts
+
+	// namespaceTimeouts holds the callback timeouts set for namespace
+	// patterns, the last one set first.
+	namespaceTimeouts []namespaceTimeout
+}
+
+// clone returns a copy of config which can be modified.
+func (config *hookConfig) clone() *hookConfig {
+	c := &hookConfig{
+		errorPolicies:     make(map[string]ErrorPolicy, len(config.errorPolicies)),
+		panicPolicies:     make(map[string]PanicPolicy, len(config.panicPolicies)),
+		parallel:          make(map[string]int, len(config.parallel)),
+		timeouts:          make(map[string]Timeouts, len(config.timeouts)),
+		namespaceTimeouts: config.namespaceTimeouts,
+	}
+	for k, v := range config.errorPolicies {
+		c.errorPolicies[k] = v
+	}
+	for k, v := range config.panicPolicies {
+		c.panicPolicies[k] = v
+	}
+	for k, v := range config.parallel {
+		c.parallel[k] = v
+	}
+	for k, v := range config.timeouts {
+		c.timeouts[k] = v
+	}
+	return c
+}
+
+// configure replaces the settings of the hooks by a copy modified by update.
+func (hooks *Hooks) configure(update func(config *hookConfig)) {
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+
+	config := hooks.dispatch.config.Load().clone()
+	update(config)
+	hooks.dispatch.config.Store(config)
+}
+
+// lookup returns the state of a hook, or nil if the hook has no entry. It
+// must be called without holding hooks.mu.
+func (hooks *Hooks) lookup(hookName string) *hookState {
+	d := hooks.dispatch
+	if state, ok := (*d.read.Load())[hookName]; ok {
+		return state
+	}
+
+	hooks.mu.RLock()
+	state := d.states[hookName]
+	n := len(d.states)
+	hooks.mu.RUnlock()
+
+	if d.misses.Add(1) > int64(n) {
+		hooks.mu.Lock()
+		if d.misses.Load() > int64(len(d.states)) {
+			read := make(map[string]*hookState, len(d.states))
+			for name, state := range d.states {
+				read[name] = state
+			}
+			d.read.Store(&read)
+			d.misses.Store(0)
+		}
+		hooks.mu.Unlock()
+	}
+
+	return state
+}
+
+// state returns the state of a hook, creating its entry if needed.
+func (hooks *Hooks) state(hookName string) *hookState {
+	if state := hooks.lookup(hookName); state != nil {
+		return state
+	}
+
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+
+	if _, ok := hooks.Hooks[hookName]; !ok {
+		hooks.addEntry(hookName)
+	}
+	return hooks.dispatch.states[hookName]
+}
+
+// publish makes the current handlers of a hook visible to its runs. It must
+// be called with hooks.mu held after every change of the handlers.
+func (hooks *Hooks) publish(hookName string) {
+	state := hooks.dispatch.states[hookName]
+	if state == nil {
+		state = &hookState{}
+		hooks.dispatch.states[hookName] = state
+
+		if hookName == "all" {
+			hooks.dispatch.observers.Store(state)
+		}
+	}
+
+	snapshot := append([]Handler(nil), hooks.Hooks[hookName].Handlers...)
+	state.handlers.Store(&snapshot)
+}
+
+// next returns the index of the handler to run after the handler at index i
+// of snapshot, along with the snapshot the index refers to. When the handlers
+// of the hook changed in the meantime, the run goes on after the handler at
+// index i in the new snapshot or, if it was removed, after the closest
+// handler before it which is still there, so that the handlers added after
+// it run and the others do not run again.
+func (state *hookState) next(snapshot *[]Handler, i int) (*[]Handler, int) {
+	latest := state.handlers.Load()
+	if latest == snapshot {
+		return snapshot, i + 1
+	}
+
+	previous := *snapshot
+	for j := i; j >= 0; j-- {
+		for k, handler := range *latest {
+			if handler.id == previous[j].id {
+				return latest, k + 1
+			}
+		}
+	}
+
+	return latest, 0
+}
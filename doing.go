@@ -0,0 +1,27 @@
+package hooks
+
+// DoingAction returns whether an action, or any action if hookName is empty,
+// is running in the calling goroutine.
+func (e *Engine) DoingAction(hookName string) bool {
+	return doingHook(e.actions, hookName)
+}
+
+// DoingFilter returns whether a filter, or any filter if hookName is empty,
+// is running in the calling goroutine.
+func (e *Engine) DoingFilter(hookName string) bool {
+	return doingHook(e.filters, hookName)
+}
+
+// doingHook returns whether a hook is currently being executed, either
+// directly or around the hooks run by its callbacks.
+func doingHook(hooks *Hooks, hookName string) bool {
+	for _, run := range hooks.stack() {
+		// If the hookName was not passed
+		// or if any running hook is the one we're looking for
+		if run.kind == hooks.kind && (hookName == "" || run.name == hookName) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,151 @@
+package hooks
+
+import (
+	"context"
+	"time"
+)
+
+// Registry adds, removes and configures the callbacks of actions and
+// filters.
+type Registry interface {
+	AddAction(hookName string, namespace string, callback func(...interface{}) interface{}, priority int)
+	AddFilter(hookName string, namespace string, callback func(...interface{}) interface{}, priority int)
+	AddActionContext(hookName string, namespace string, callback func(context.Context, ...interface{}) interface{}, priority int)
+	AddFilterContext(hookName string, namespace string, callback func(context.Context, ...interface{}) interface{}, priority int)
+	AddActionE(hookName string, namespace string, callback func(context.Context, ...interface{}) (interface{}, error), priority int)
+	AddFilterE(hookName string, namespace string, callback func(context.Context, ...interface{}) (interface{}, error), priority int)
+	AddActionOrdered(hookName string, namespace string, callback func(...interface{}) interface{}, priority int, order Order) error
+	AddFilterOrdered(hookName string, namespace string, callback func(...interface{}) interface{}, priority int, order Order) error
+	AddActionPattern(pattern string, namespace string, callback func(...interface{}) interface{}, priority int)
+	AddFilterPattern(pattern string, namespace string, callback func(...interface{}) interface{}, priority int)
+	SubscribeAction(hookName string, namespace string, callback func(...interface{}) interface{}, priority int) *Subscription
+	SubscribeFilter(hookName string, namespace string, callback func(...interface{}) interface{}, priority int) *Subscription
+	SubscribeActionE(hookName string, namespace string, callback func(context.Context, ...interface{}) (interface{}, error), priority int) *Subscription
+	SubscribeFilterE(hookName string, namespace string, callback func(context.Context, ...interface{}) (interface{}, error), priority int) *Subscription
+
+	RemoveAction(hookName string, namespace string) int
+	RemoveFilter(hookName string, namespace string) int
+	RemoveAllActions(hookName string, namespace string) int
+	RemoveAllFilters(hookName string, namespace string) int
+	RemoveActionPattern(pattern string, namespace string) int
+	RemoveFilterPattern(pattern string, namespace string) int
+	RemoveNamespace(pattern string) int
+	SuspendNamespace(pattern string) int
+	ResumeNamespace(pattern string) int
+
+	HasAction(hookName string) bool
+	HasFilter(hookName string) bool
+
+	SetActionErrorPolicy(hookName string, policy ErrorPolicy)
+	SetFilterErrorPolicy(hookName string, policy ErrorPolicy)
+	SetActionPanicPolicy(hookName string, policy PanicPolicy)
+	SetFilterPanicPolicy(hookName string, policy PanicPolicy)
+	SetActionParallel(hookName string, concurrency int)
+	SetActionTimeout(hookName string, timeouts Timeouts)
+	SetFilterTimeout(hookName string, timeouts Timeouts)
+	SetNamespaceTimeout(pattern string, timeout time.Duration)
+	SetActionBreaker(hookName string, options BreakerOptions)
+	SetFilterBreaker(hookName string, options BreakerOptions)
+	ActionBreakers() []BreakerInfo
+	FilterBreakers() []BreakerInfo
+
+	ListHooks() []HookSummary
+	ActionHandlers(hookName string) []HandlerInfo
+	FilterHandlers(hookName string) []HandlerInfo
+
+	// WithOwner returns a Registry recording owner as the Owner of the
+	// callbacks added through it, such as the one of a plugin. A decorator
+	// should wrap the Registry returned, or the callbacks added through it
+	// bypass the decorator.
+	WithOwner(owner string) Registry
+	// RemoveOwned removes the callbacks whose Owner is owner, triggering the
+	// HookRemoved action for each of them, and returns how many were removed.
+	RemoveOwned(owner string) int
+}
+
+// Dispatcher runs actions and filters, and tells about the hooks running or
+// which have run.
+type Dispatcher interface {
+	DoAction(hookName string, args ...interface{}) interface{}
+	ApplyFilters(hookName string, args ...interface{}) interface{}
+	DoActionContext(ctx context.Context, hookName string, args ...interface{}) error
+	ApplyFiltersContext(ctx context.Context, hookName string, args ...interface{}) (interface{}, error)
+	DoActionE(hookName string, args ...interface{}) error
+	ApplyFiltersE(hookName string, args ...interface{}) (interface{}, error)
+	DoActionCollect(ctx context.Context, hookName string, args ...interface{}) ([]interface{}, error)
+	Hook(hookName string) *Hook
+
+	CurrentAction() (HookInfo, error)
+	CurrentFilter() (HookInfo, error)
+	DoingAction(hookName string) bool
+	DoingFilter(hookName string) bool
+	DidAction(hookName string) int
+	DidFilter(hookName string) int
+	StopAction() error
+	StopFilter() error
+	Stack() []HookInfo
+}
+
+// Interface is the whole API of a hooks system. Engine implements it; other
+// implementations, such as decorators embedding an Interface and overriding
+// some of its methods, or fakes in tests, can be used wherever an Interface
+// is expected.
+type Interface interface {
+	Registry
+	Dispatcher
+}
+
+var _ Interface = (*Engine)(nil)
+
+// Engine is the method-based implementation of Interface, on which the
+// functions of a Core are bound. Unlike those of a Core, the methods of an
+// Engine cannot be replaced by its users. All of them are safe for concurrent
+// use by multiple goroutines.
+type Engine struct {
+	actions *Hooks
+	filters *Hooks
+
+	// owner is recorded as the Owner of the handlers added through the
+	// Engine.
+	owner string
+}
+
+// New returns an Engine with its own actions and filters.
+func New() *Engine {
+	// The actions and filters running in a goroutine share its stack.
+	group := newRunGroup()
+	actions := newHooks(KindAction, group)
+	filters := newHooks(KindFilter, group)
+
+	return &Engine{actions: &actions, filters: &filters}
+}
+
+// Engine returns the Engine the functions of core were bound to, as a way to
+// pass a Core where an Interface is expected, or nil if they were bound to
+// another implementation of Registry. Later changes to the functions of core
+// do not affect the Engine.
+func (core *Core) Engine() *Engine {
+	return core.engine
+}
+
+// Core returns a Core whose functions are bound to the methods of the Engine,
+// for the code using the field-based API.
+func (e *Engine) Core() *Core {
+	core := &Core{}
+	bindCore(core, e, e)
+	return core
+}
+
+// WithOwner returns an Engine working on the same actions and filters, which
+// records owner as the Owner of the callbacks added through it.
+func (e *Engine) WithOwner(owner string) Registry {
+	return &Engine{actions: e.actions, filters: e.filters, owner: owner}
+}
+
+// RemoveOwned removes the callbacks whose Owner is owner from every action and
+// filter. It returns the number of callbacks removed.
+func (e *Engine) RemoveOwned(owner string) int {
+	return e.removeHandlers(func(handler Handler) bool {
+		return handler.Owner == owner
+	})
+}
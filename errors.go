@@ -0,0 +1,115 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ErrorPolicy decides what happens to the rest of a hook's callbacks when one
+// of them returns an error.
+type ErrorPolicy int
+
+const (
+	// StopOnError skips the remaining callbacks and returns the error. It is
+	// the default policy.
+	StopOnError ErrorPolicy = iota
+	// ContinueOnError runs the remaining callbacks and returns all the errors
+	// joined together.
+	ContinueOnError
+	// IgnoreErrors runs the remaining callbacks and returns no error.
+	IgnoreErrors
+)
+
+// PanicPolicy decides what happens when a callback panics.
+type PanicPolicy int
+
+const (
+	// PropagatePanic lets the panic unwind through the caller of the hook. It
+	// is the default policy.
+	PropagatePanic PanicPolicy = iota
+	// ContinueOnPanic recovers from the panic and runs the remaining
+	// callbacks.
+	ContinueOnPanic
+	// AbortOnPanic recovers from the panic and skips the remaining callbacks.
+	AbortOnPanic
+)
+
+// HookError wraps an error returned by a callback with the handler it comes
+// from.
+type HookError struct {
+	Hook      string
+	Namespace string
+	Priority  int
+	Err       error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("hook %s: %s (priority %d): %v", e.Hook, e.Namespace, e.Priority, e.Err)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is returned for a callback that panicked while its hook's
+// PanicPolicy recovers from panics. It is also passed to the HookFailed
+// action.
+type PanicError struct {
+	Hook      string
+	Namespace string
+	Priority  int
+	// Value is the value the callback panicked with.
+	Value interface{}
+	// Stack is the stack trace of the goroutine at the time of the panic.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("hook %s: %s (priority %d): panic: %v", e.Hook, e.Namespace, e.Priority, e.Value)
+}
+
+// Unwrap returns the value of the panic if it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// TimeoutPolicy decides what happens when a callback runs out of time.
+type TimeoutPolicy int
+
+const (
+	// SkipOnTimeout goes on without the value of the callback, so a filter
+	// keeps its previous value. When the budget of the hook is exhausted,
+	// the remaining callbacks are skipped. No error is returned. It is the
+	// default policy.
+	SkipOnTimeout TimeoutPolicy = iota
+	// AbortOnTimeout skips the remaining callbacks and returns a
+	// TimeoutError.
+	AbortOnTimeout
+)
+
+// TimeoutError reports a callback which did not return in time. It is also
+// passed to the HookTimedOut action. When the budget of a hook was exhausted
+// before a callback could start, Namespace is empty.
+type TimeoutError struct {
+	Hook      string
+	Namespace string
+	Priority  int
+	// Timeout is the time the callback, or the hook, was given.
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Namespace == "" {
+		return fmt.Sprintf("hook %s: budget of %s exhausted", e.Hook, e.Timeout)
+	}
+	return fmt.Sprintf("hook %s: %s (priority %d): timed out after %s", e.Hook, e.Namespace, e.Priority, e.Timeout)
+}
+
+// Unwrap returns context.DeadlineExceeded.
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
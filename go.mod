@@ -1,3 +1,3 @@
 module github.com/Golang-Hooks/Golang-Hooks
 
-go 1.18
+go 1.20
@@ -0,0 +1,125 @@
+package hooks
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Hook is a handle on the action and the filter of a hook name, returned by
+// Core.Hook. It resolves the hook once instead of looking its name up on
+// every call, and stays valid as callbacks are added to or removed from the
+// hook. Running a hook without callbacks through a Hook does not allocate.
+type Hook struct {
+	engine *Engine
+	name   string
+	// observed is set unless the hook is one of the metaHooks.
+	observed bool
+
+	// action and filter hold the state of the action and of the filter once
+	// they have an entry, which is never removed.
+	action atomic.Pointer[hookState]
+	filter atomic.Pointer[hookState]
+}
+
+// Hook returns a Hook for a hook name.
+func (e *Engine) Hook(hookName string) *Hook {
+	return &Hook{engine: e, name: hookName, observed: !metaHooks[hookName]}
+}
+
+// Name returns the name of the hook.
+func (h *Hook) Name() string {
+	return h.name
+}
+
+// Do runs the action like DoAction.
+func (h *Hook) Do(args ...interface{}) {
+	h.run(context.Background(), KindAction, false, nil, args)
+}
+
+// DoContext runs the action like DoActionContext.
+func (h *Hook) DoContext(ctx context.Context, args ...interface{}) error {
+	_, err := h.run(ctx, KindAction, false, nil, args)
+	return err
+}
+
+// Apply runs the filter on value like ApplyFilters, passing args to the
+// callbacks after it.
+func (h *Hook) Apply(value interface{}, args ...interface{}) interface{} {
+	v, _ := h.run(context.Background(), KindFilter, true, value, args)
+	return v
+}
+
+// ApplyContext runs the filter on value like ApplyFiltersContext.
+func (h *Hook) ApplyContext(ctx context.Context, value interface{}, args ...interface{}) (interface{}, error) {
+	return h.run(ctx, KindFilter, true, value, args)
+}
+
+// Has returns whether the action or the filter exists, like HasAction and
+// HasFilter.
+func (h *Hook) Has(kind Kind) bool {
+	return h.lookup(kind) != nil
+}
+
+// Did returns the number of times the action or the filter has been run, like
+// DidAction and DidFilter.
+func (h *Hook) Did(kind Kind) int {
+	if state := h.lookup(kind); state != nil {
+		return int(state.runs.Load())
+	}
+	return 0
+}
+
+// registry returns the registry of the action or the filter, and where its
+// state is kept.
+func (h *Hook) registry(kind Kind) (*Hooks, *atomic.Pointer[hookState]) {
+	if kind == KindFilter {
+		return h.engine.filters, &h.filter
+	}
+	return h.engine.actions, &h.action
+}
+
+// lookup returns the state of the action or the filter, or nil if it has no
+// entry.
+func (h *Hook) lookup(kind Kind) *hookState {
+	hooks, cached := h.registry(kind)
+	if state := cached.Load(); state != nil {
+		return state
+	}
+
+	state := hooks.lookup(h.name)
+	if state != nil {
+		cached.Store(state)
+	}
+	return state
+}
+
+// run runs the action or the filter like runHook. The arguments are copied
+// before they are handed to the callbacks, so that they do not escape when
+// there are none.
+func (h *Hook) run(ctx context.Context, kind Kind, returnFirstArg bool, value interface{}, args []interface{}) (interface{}, error) {
+	hooks, cached := h.registry(kind)
+
+	state := cached.Load()
+	if state == nil {
+		state = hooks.state(h.name)
+		cached.Store(state)
+	}
+
+	if len(*state.handlers.Load()) == 0 && !(h.observed && h.engine.observed()) {
+		state.runs.Add(1)
+		return value, nil
+	}
+
+	var all []interface{}
+	if returnFirstArg {
+		all = make([]interface{}, 0, len(args)+1)
+		all = append(all, value)
+	}
+	all = append(all, args...)
+
+	if h.observed {
+		h.engine.observe(ctx, kind, h.name, all)
+	}
+
+	return h.engine.runState(ctx, hooks, h.name, state, returnFirstArg, all, nil)
+}
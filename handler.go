@@ -0,0 +1,54 @@
+package hooks
+
+import (
+	"context"
+	"runtime/debug"
+)
+
+// call invokes the callback of the handler. Callbacks registered without a
+// context simply don't receive it, and only ErrorCallback can fail.
+func (handler *Handler) call(ctx context.Context, args []interface{}) (interface{}, error) {
+	switch {
+	case handler.ErrorCallback != nil:
+		return handler.ErrorCallback(ctx, args...)
+	case handler.ContextCallback != nil:
+		return handler.ContextCallback(ctx, args...), nil
+	}
+	return handler.Callback(args...), nil
+}
+
+// callRecover is like call, but recovers from a panic of the callback and
+// returns it as a PanicError.
+func (handler *Handler) callRecover(ctx context.Context, hookName string, args []interface{}) (result interface{}, panicErr *PanicError, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr = &PanicError{
+				Hook:      hookName,
+				Namespace: handler.Namespace,
+				Priority:  handler.Priority,
+				Value:     r,
+				Stack:     debug.Stack(),
+			}
+		}
+	}()
+
+	result, err = handler.call(ctx, args)
+	return result, nil, err
+}
+
+// active returns whether the handler runs when its hook runs.
+func (handler *Handler) active() bool {
+	return !handler.disabled && !handler.suspended
+}
+
+// callback returns the function registered for the handler, whichever its
+// signature is.
+func (handler *Handler) callback() interface{} {
+	switch {
+	case handler.ErrorCallback != nil:
+		return handler.ErrorCallback
+	case handler.ContextCallback != nil:
+		return handler.ContextCallback
+	}
+	return handler.Callback
+}
@@ -0,0 +1,11 @@
+package hooks
+
+// HasAction returns whether an action exists.
+func (e *Engine) HasAction(hookName string) bool {
+	return e.actions.lookup(hookName) != nil
+}
+
+// HasFilter returns whether a filter exists.
+func (e *Engine) HasFilter(hookName string) bool {
+	return e.filters.lookup(hookName) != nil
+}
@@ -1,8 +1,93 @@
 package hooks
 
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+// metaHooks are the actions triggered by the package itself. The all hook
+// does not observe them.
+var metaHooks = map[string]bool{
+	"all":         true,
+	"HookAdded":   true,
+	"HookRemoved": true,
+	"HookFailed":  true,
+
+	"HookTimedOut": true,
+
+	"BreakerStateChanged": true,
+}
+
 // insert inserts an element at a specific index.
 func insert[T any](a []T, index int, value T) []T {
 	a = append(a[:index+1], a[index:]...)
 	a[index] = value
 	return a
 }
+
+// joinErrors is like errors.Join, except that a single error is returned as
+// is so that it can still be compared directly.
+func joinErrors(errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// matchNamespace returns whether a namespace matches a pattern. A pattern
+// ending with "/*" matches every namespace below its prefix, at any depth;
+// other patterns follow path.Match, so an exact namespace matches itself.
+func matchNamespace(pattern string, namespace string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(namespace, prefix)
+	}
+
+	matched, err := path.Match(pattern, namespace)
+	return err == nil && matched
+}
+
+// matchAnyNamespace returns whether a namespace matches one of the patterns.
+func matchAnyNamespace(patterns []string, namespace string) bool {
+	for _, pattern := range patterns {
+		if matchNamespace(pattern, namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchHookName returns whether a hook name matches a hook pattern. Names and
+// patterns are made of segments separated by dots: a "#" segment matches zero
+// or more segments, and other segments follow path.Match, so that "*" matches
+// exactly one segment and "save_post_*" any segment starting with
+// "save_post_".
+func matchHookName(pattern string, hookName string) bool {
+	return matchSegments(strings.Split(pattern, "."), strings.Split(hookName, "."))
+}
+
+func matchSegments(pattern []string, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "#" {
+			for i := len(segments); i >= 0; i-- {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+
+		if matched, err := path.Match(pattern[0], segments[0]); err != nil || !matched {
+			return false
+		}
+
+		pattern = pattern[1:]
+		segments = segments[1:]
+	}
+
+	return len(segments) == 0
+}
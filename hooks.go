@@ -1,31 +1,96 @@
 package hooks
 
+import "sync"
+
+// newHooks returns an empty, ready to use Hooks registry, whose running hooks
+// belong to group.
+func newHooks(kind Kind, group *runGroup) Hooks {
+	return Hooks{
+		Hooks:    make(map[string]Handlers),
+		kind:     kind,
+		breakers: newBreakers(),
+		dispatch: newDispatch(group),
+		mu:       &sync.RWMutex{},
+	}
+}
+
+// CreateHooks returns a new Core, whose functions are bound to a new Engine.
+// All of its functions are safe for concurrent use by multiple goroutines.
 func CreateHooks() Core {
-	actions := Hooks{}
-	actions.Hooks = make(map[string]Handlers)
-	filters := Hooks{}
-	filters.Hooks = make(map[string]Handlers)
+	e := New()
 
 	rv := Core{}
-
-	rv.AddAction = createAddHook(&rv, &actions)
-	rv.DoAction = createRunHook(&rv, &actions, false)
-	rv.AddFilter = createAddHook(&rv, &filters)
-	rv.ApplyFilters = createRunHook(&rv, &filters, true)
-	rv.CurrentAction = createCurrentHook(&rv, &actions)
-	rv.CurrentFilter = createCurrentHook(&rv, &filters)
-	rv.DidAction = createDidHook(&rv, &actions)
-	rv.DidFilter = createDidHook(&rv, &filters)
-	rv.DoingAction = createDoingHook(&rv, &actions)
-	rv.DoingFilter = createDoingHook(&rv, &filters)
-	rv.HasAction = createHasHook(&rv, &actions)
-	rv.HasFilter = createHasHook(&rv, &filters)
-	rv.RemoveAction = createRemoveHook(&rv, &actions, false)
-	rv.RemoveFilter = createRemoveHook(&rv, &filters, false)
-	rv.RemoveAllActions = createRemoveHook(&rv, &actions, true)
-	rv.RemoveAllFilters = createRemoveHook(&rv, &filters, true)
-	rv.Actions = actions
-	rv.Filters = filters
+	bindCore(&rv, e, e)
 
 	return rv
 }
+
+// bindCore sets the functions of rv to the methods of registry and
+// dispatcher. Several Cores can be bound to the same Engine, such as the Core
+// given to a Plugin, whose registry records the plugin as the owner of the
+// callbacks it adds.
+func bindCore(rv *Core, registry Registry, dispatcher Dispatcher) {
+	rv.AddAction = registry.AddAction
+	rv.DoAction = dispatcher.DoAction
+	rv.AddFilter = registry.AddFilter
+	rv.ApplyFilters = dispatcher.ApplyFilters
+	rv.CurrentAction = dispatcher.CurrentAction
+	rv.CurrentFilter = dispatcher.CurrentFilter
+	rv.DidAction = dispatcher.DidAction
+	rv.DidFilter = dispatcher.DidFilter
+	rv.DoingAction = dispatcher.DoingAction
+	rv.DoingFilter = dispatcher.DoingFilter
+	rv.HasAction = registry.HasAction
+	rv.HasFilter = registry.HasFilter
+	rv.RemoveAction = registry.RemoveAction
+	rv.RemoveFilter = registry.RemoveFilter
+	rv.RemoveAllActions = registry.RemoveAllActions
+	rv.RemoveAllFilters = registry.RemoveAllFilters
+	rv.AddActionContext = registry.AddActionContext
+	rv.AddFilterContext = registry.AddFilterContext
+	rv.DoActionContext = dispatcher.DoActionContext
+	rv.ApplyFiltersContext = dispatcher.ApplyFiltersContext
+	rv.AddActionE = registry.AddActionE
+	rv.AddFilterE = registry.AddFilterE
+	rv.DoActionE = dispatcher.DoActionE
+	rv.ApplyFiltersE = dispatcher.ApplyFiltersE
+	rv.SetActionErrorPolicy = registry.SetActionErrorPolicy
+	rv.SetFilterErrorPolicy = registry.SetFilterErrorPolicy
+	rv.SetActionPanicPolicy = registry.SetActionPanicPolicy
+	rv.SetFilterPanicPolicy = registry.SetFilterPanicPolicy
+	rv.SubscribeAction = registry.SubscribeAction
+	rv.SubscribeFilter = registry.SubscribeFilter
+	rv.SubscribeActionE = registry.SubscribeActionE
+	rv.SubscribeFilterE = registry.SubscribeFilterE
+	rv.StopAction = dispatcher.StopAction
+	rv.StopFilter = dispatcher.StopFilter
+	rv.AddActionOrdered = registry.AddActionOrdered
+	rv.AddFilterOrdered = registry.AddFilterOrdered
+	rv.AddActionPattern = registry.AddActionPattern
+	rv.AddFilterPattern = registry.AddFilterPattern
+	rv.RemoveActionPattern = registry.RemoveActionPattern
+	rv.RemoveFilterPattern = registry.RemoveFilterPattern
+	rv.RemoveNamespace = registry.RemoveNamespace
+	rv.SuspendNamespace = registry.SuspendNamespace
+	rv.ResumeNamespace = registry.ResumeNamespace
+	rv.SetActionParallel = registry.SetActionParallel
+	rv.DoActionCollect = dispatcher.DoActionCollect
+	rv.SetActionTimeout = registry.SetActionTimeout
+	rv.SetFilterTimeout = registry.SetFilterTimeout
+	rv.SetNamespaceTimeout = registry.SetNamespaceTimeout
+	rv.SetActionBreaker = registry.SetActionBreaker
+	rv.SetFilterBreaker = registry.SetFilterBreaker
+	rv.ActionBreakers = registry.ActionBreakers
+	rv.FilterBreakers = registry.FilterBreakers
+	rv.Hook = dispatcher.Hook
+	rv.ListHooks = registry.ListHooks
+	rv.ActionHandlers = registry.ActionHandlers
+	rv.FilterHandlers = registry.FilterHandlers
+	rv.Stack = dispatcher.Stack
+
+	if e, ok := registry.(*Engine); ok {
+		rv.engine = e
+		rv.Actions = *e.actions
+		rv.Filters = *e.filters
+	}
+}
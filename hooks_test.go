@@ -1,9 +1,20 @@
 package hooks_test
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	hooks "github.com/Golang-Hooks/Golang-Hooks"
 )
@@ -463,14 +474,14 @@ func TestRemoveActionCallback(t *testing.T) {
 	h.AddAction("test.action", "my_callback_action_b", actionB, 2)
 	h.AddAction("test.action", "my_callback_action_b", actionC, 8)
 
-	expected := 1
+	expected := 2
 	ra := h.RemoveAction("test.action", "my_callback_action_b")
 	if ra != expected {
 		t.Errorf("Expected %d to be equal to %d", ra, expected)
 	}
 
 	h.DoAction("test.action")
-	expected2 := "ca"
+	expected2 := "a"
 	if actionValue != expected2 {
 		t.Errorf("Expected %s to be equal to %s", actionValue, expected2)
 	}
@@ -621,6 +632,35 @@ func TestRemoveFilterCallbackSamePriority(t *testing.T) {
 	}
 }
 
+// Remove the filter callbacks sharing a namespace
+func TestRemoveFilterCallbacksSameNamespace(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	h.AddFilter("test.filter", "my_callback_filter", filterA, 10)
+	h.AddFilter("test.filter", "my_callback_filter", filterB, 11)
+	h.AddFilter("test.filter", "my_callback_filter_c", filterC, 12)
+	h.AddFilter("test.filter", "my_callback_filter", filterA, 13)
+
+	if removed := h.RemoveFilter("test.filter", "my_callback_filter"); removed != 3 {
+		t.Errorf("Expected %v to be equal to %v", removed, 3)
+	}
+
+	v := h.ApplyFilters("test.filter", "test")
+	expected := "testc"
+	if v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+
+	h.AddFilter("test.filter", "my_callback_filter_b", filterB, 10)
+
+	v = h.ApplyFilters("test.filter", "test")
+	expected = "testbc"
+	if v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+}
+
 // Remove all filter callbacks
 func TestRemoveAllFilterCallbacks(t *testing.T) {
 	teardownTest := setupTest(t)
@@ -1177,3 +1217,2527 @@ func TestRemoveFilterTriggersHookRemoved(t *testing.T) {
 		t.Errorf("Expected %d to be equal to %d", numberOfCalls, expected)
 	}
 }
+
+// Add, remove and run hooks from many goroutines at the same time
+func TestConcurrentAddRemoveRun(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var wg sync.WaitGroup
+
+	for g := 0; g < 8; g++ {
+		namespace := fmt.Sprintf("my_callback_%d", g)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				h.AddFilter("test.filter", namespace, filterA, i%5)
+				h.AddAction("test.action", namespace, func(i ...interface{}) interface{} {
+					h.DoingAction("test.action")
+					h.CurrentAction()
+					return nil
+				}, i%5)
+				h.RemoveFilter("test.filter", namespace)
+				h.RemoveAction("test.action", namespace)
+			}
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				h.ApplyFilters("test.filter", "test")
+				h.DoAction("test.action")
+				h.DidFilter("test.filter")
+				h.HasAction("test.action")
+				h.DoingFilter("")
+				h.CurrentFilter()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	expected := 800
+	if v := h.DidFilter("test.filter"); v != expected {
+		t.Errorf("Expected %d to be equal to %d", v, expected)
+	}
+
+	if v := h.DidAction("test.action"); v != expected {
+		t.Errorf("Expected %d to be equal to %d", v, expected)
+	}
+
+	if h.DoingAction("") || h.DoingFilter("") {
+		t.Errorf("Expected no hook to be running.")
+	}
+}
+
+// Concurrent dispatches each see their own running hook
+func TestCurrentActionPerGoroutine(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var inside sync.WaitGroup
+	inside.Add(2)
+	release := make(chan struct{})
+
+	check := func(i ...interface{}) interface{} {
+		inside.Done()
+		<-release
+
+		expected := i[0].(string)
+		if hi, err := h.CurrentAction(); err != nil || hi.Name != expected {
+			t.Errorf("Expected %s to be equal to %s", hi.Name, expected)
+		}
+
+		if !h.DoingAction(expected) {
+			t.Errorf("Expected %s action to be running.", expected)
+		}
+
+		if _, err := h.CurrentFilter(); err == nil {
+			t.Errorf("Expected no current filter.")
+		}
+		return nil
+	}
+
+	h.AddAction("test.action1", "my_callback", check, 10)
+	h.AddAction("test.action2", "my_callback", check, 10)
+
+	var done sync.WaitGroup
+	done.Add(2)
+	go func() {
+		defer done.Done()
+		h.DoAction("test.action1", "test.action1")
+	}()
+	go func() {
+		defer done.Done()
+		h.DoAction("test.action2", "test.action2")
+	}()
+
+	// Both actions are running at this point.
+	inside.Wait()
+
+	if h.DoingAction("") {
+		t.Errorf("Expected no action to be running on this goroutine.")
+	}
+
+	close(release)
+	done.Wait()
+}
+
+// Context callbacks receive the context passed to DoActionContext
+func TestDoActionContext(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "request")
+
+	h.AddActionContext("test.action", "my_callback", func(ctx context.Context, i ...interface{}) interface{} {
+		if v, ok := ctx.Value(key{}).(string); ok {
+			actionValue += v
+		}
+		actionValue += "a"
+		return nil
+	}, 10)
+	h.AddAction("test.action", "my_callback", actionB, 11)
+
+	if err := h.DoActionContext(ctx, "test.action"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	// Context callbacks also run from DoAction.
+	h.DoAction("test.action")
+
+	expected := "requestabab"
+	if actionValue != expected {
+		t.Errorf("Expected %s to be equal to %s", actionValue, expected)
+	}
+}
+
+// Cancelling the context stops the filter chain between callbacks
+func TestApplyFiltersContextCancel(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h.AddFilter("test.filter", "my_callback_filter_a", filterA, 1)
+	h.AddFilterContext("test.filter", "my_callback_cancel", func(ctx context.Context, i ...interface{}) interface{} {
+		cancel()
+		return i[0].(string) + "b"
+	}, 2)
+	h.AddFilter("test.filter", "my_callback_filter_c", filterC, 3)
+
+	v, err := h.ApplyFiltersContext(ctx, "test.filter", "test")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v to be equal to %v", err, context.Canceled)
+	}
+
+	expected := "testab"
+	if v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+
+	if h.DoingFilter("") {
+		t.Errorf("Expected filter to not be running.")
+	}
+
+	v, err = h.ApplyFiltersContext(context.Background(), "test.filter", "test")
+	expected = "testabc"
+	if err != nil || v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+}
+
+// Error callbacks stop the filter chain by default
+func TestApplyFiltersEStopOnError(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	errInvalid := errors.New("invalid")
+
+	h.AddFilter("test.filter", "my_callback_filter_a", filterA, 1)
+	h.AddFilterE("test.filter", "my_callback_validate", func(ctx context.Context, i ...interface{}) (interface{}, error) {
+		return nil, errInvalid
+	}, 2)
+	h.AddFilter("test.filter", "my_callback_filter_c", filterC, 3)
+
+	v, err := h.ApplyFiltersE("test.filter", "test")
+	if !errors.Is(err, errInvalid) {
+		t.Errorf("Expected %v to be equal to %v", err, errInvalid)
+	}
+
+	var hookErr *hooks.HookError
+	if !errors.As(err, &hookErr) || hookErr.Namespace != "my_callback_validate" || hookErr.Priority != 2 {
+		t.Errorf("Expected %v to come from my_callback_validate", err)
+	}
+
+	expected := "testa"
+	if v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+
+	// The plain variant runs the same chain and drops the error.
+	if v := h.ApplyFilters("test.filter", "test"); v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+}
+
+// Error policies decide whether the chain continues after an error
+func TestDoActionEErrorPolicies(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	errA := errors.New("a")
+	errB := errors.New("b")
+
+	h.AddActionE("test.action", "my_callback_a", func(ctx context.Context, i ...interface{}) (interface{}, error) {
+		actionValue += "a"
+		return nil, errA
+	}, 1)
+	h.AddActionE("test.action", "my_callback_b", func(ctx context.Context, i ...interface{}) (interface{}, error) {
+		actionValue += "b"
+		return nil, errB
+	}, 2)
+	h.AddAction("test.action", "my_callback_c", actionC, 3)
+
+	err := h.DoActionE("test.action")
+	if !errors.Is(err, errA) || errors.Is(err, errB) {
+		t.Errorf("Expected %v to be equal to %v", err, errA)
+	}
+
+	h.SetActionErrorPolicy("test.action", hooks.ContinueOnError)
+	err = h.DoActionE("test.action")
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("Expected %v to contain %v and %v", err, errA, errB)
+	}
+
+	h.SetActionErrorPolicy("test.action", hooks.IgnoreErrors)
+	if err := h.DoActionE("test.action"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	// The default policy applies to hooks without their own policy.
+	h.AddActionE("another.action", "my_callback_a", func(ctx context.Context, i ...interface{}) (interface{}, error) {
+		return nil, errA
+	}, 1)
+	h.SetActionErrorPolicy("", hooks.IgnoreErrors)
+	if err := h.DoActionE("another.action"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	h.SetActionErrorPolicy("test.action", hooks.StopOnError)
+	if err := h.DoActionE("test.action"); !errors.Is(err, errA) {
+		t.Errorf("Expected %v to be equal to %v", err, errA)
+	}
+
+	expected := "aabcabca"
+	if actionValue != expected {
+		t.Errorf("Expected %s to be equal to %s", actionValue, expected)
+	}
+}
+
+// A panicking callback does not leave a stale running hook behind
+func TestPanicPropagates(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	h.AddAction("test.action", "my_callback_panics", func(i ...interface{}) interface{} {
+		panic("boom")
+	}, 10)
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("Expected %v to be equal to %s", r, "boom")
+			}
+		}()
+		h.DoAction("test.action")
+	}()
+
+	if h.DoingAction("") {
+		t.Errorf("Expected action to not be running.")
+	}
+
+	if _, err := h.CurrentAction(); err == nil {
+		t.Errorf("Expected no current action.")
+	}
+}
+
+// Recovered panics continue or abort the chain and trigger HookFailed
+func TestPanicPolicies(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var failures []*hooks.PanicError
+	h.AddAction("HookFailed", "my_callback", func(i ...interface{}) interface{} {
+		if p, ok := i[3].(*hooks.PanicError); ok {
+			failures = append(failures, p)
+		}
+		return nil
+	}, 10)
+
+	h.AddFilter("test.filter", "my_callback_filter_a", filterA, 1)
+	h.AddFilter("test.filter", "my_callback_panics", func(i ...interface{}) interface{} {
+		panic("boom")
+	}, 2)
+	h.AddFilter("test.filter", "my_callback_filter_c", filterC, 3)
+
+	h.SetFilterPanicPolicy("test.filter", hooks.ContinueOnPanic)
+	v, err := h.ApplyFiltersE("test.filter", "test")
+	expected := "testac"
+	if v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+
+	var panicErr *hooks.PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "boom" || panicErr.Namespace != "my_callback_panics" {
+		t.Errorf("Expected %v to be a panic of my_callback_panics", err)
+	}
+
+	if len(panicErr.Stack) == 0 {
+		t.Errorf("Expected the panic to have a stack trace.")
+	}
+
+	h.SetFilterPanicPolicy("test.filter", hooks.AbortOnPanic)
+	v = h.ApplyFilters("test.filter", "test")
+	expected = "testa"
+	if v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+
+	if len(failures) != 2 || failures[0].Hook != "test.filter" || failures[1].Priority != 2 {
+		t.Errorf("Expected HookFailed to be triggered twice, got %v", failures)
+	}
+
+	if h.DoingFilter("") {
+		t.Errorf("Expected filter to not be running.")
+	}
+}
+
+// Typed filters share their chain with untyped callbacks
+func TestTypedFilter(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	content := hooks.NewFilter[string](h.Engine(), "test.filter")
+
+	content.Add("my_callback_typed", func(s string) string {
+		return s + "b"
+	}, 2)
+	h.AddFilter("test.filter", "my_callback_filter_a", filterA, 1)
+	content.AddE("my_callback_typed_e", func(ctx context.Context, s string) (string, error) {
+		return s + "c", nil
+	}, 3)
+
+	expected := "testabc"
+	if v := content.Apply("test"); v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+
+	if v := h.ApplyFilters("test.filter", "test"); v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+
+	if !content.Has() || content.Did() != 2 {
+		t.Errorf("Expected filter to exist and to be applied twice.")
+	}
+
+	// An untyped callback breaking the type is reported.
+	h.AddFilter("test.filter", "my_callback_breaks_type", func(i ...interface{}) interface{} {
+		return 42
+	}, 4)
+
+	if v, err := content.ApplyContext(context.Background(), "test"); err == nil || v != "" {
+		t.Errorf("Expected an error and an empty value, got %v and %s", err, v)
+	}
+
+	expected2 := 1
+	if v := content.Remove("my_callback_typed"); v != expected2 {
+		t.Errorf("Expected %d to be equal to %d", v, expected2)
+	}
+}
+
+// Typed actions receive their argument without type assertions
+func TestTypedAction(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	saved := hooks.NewAction[Arg1](h.Engine(), "test.action")
+
+	total := 0
+	saved.Add("my_callback", func(arg Arg1) {
+		total += arg.a
+	}, 10)
+
+	errInvalid := errors.New("invalid")
+	saved.AddE("my_callback_e", func(ctx context.Context, arg Arg1) error {
+		if arg.a < 0 {
+			return errInvalid
+		}
+		return nil
+	}, 11)
+
+	saved.Do(Arg1{a: 2})
+	h.DoAction("test.action", Arg1{a: 3})
+
+	// Untyped callers passing something else are ignored.
+	h.DoAction("test.action", "test")
+
+	if err := saved.DoContext(context.Background(), Arg1{a: -1}); !errors.Is(err, errInvalid) {
+		t.Errorf("Expected %v to be equal to %v", err, errInvalid)
+	}
+
+	expected := 4
+	if total != expected {
+		t.Errorf("Expected %d to be equal to %d", total, expected)
+	}
+
+	expected = 4
+	if v := saved.Did(); v != expected {
+		t.Errorf("Expected %d to be equal to %d", v, expected)
+	}
+}
+
+// Subscriptions remove a single callback among others of the same namespace
+func TestSubscriptionRemove(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	removed := 0
+	h.AddAction("HookRemoved", "my_callback", func(i ...interface{}) interface{} {
+		removed++
+		return nil
+	}, 10)
+
+	h.SubscribeAction("test.action", "my_callback", actionA, 10)
+	sb := h.SubscribeAction("test.action", "my_callback", actionB, 10)
+	h.SubscribeAction("test.action", "my_callback", actionC, 10)
+
+	if sb.HookName() != "test.action" || sb.Namespace() != "my_callback" {
+		t.Errorf("Expected subscription to test.action with my_callback.")
+	}
+
+	if !sb.Remove() {
+		t.Errorf("Expected callback to be removed.")
+	}
+
+	if sb.Remove() {
+		t.Errorf("Expected callback to be already removed.")
+	}
+
+	if sb.Enabled() {
+		t.Errorf("Expected removed callback to not be enabled.")
+	}
+
+	h.DoAction("test.action")
+
+	expected := "ac"
+	if actionValue != expected {
+		t.Errorf("Expected %s to be equal to %s", actionValue, expected)
+	}
+
+	if removed != 1 {
+		t.Errorf("Expected HookRemoved to be triggered once.")
+	}
+}
+
+// Callbacks returning errors can be subscribed too
+func TestSubscribeE(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	sa := h.SubscribeActionE("test.action", "my_callback", func(ctx context.Context, i ...interface{}) (interface{}, error) {
+		return nil, errors.New("failed")
+	}, 10)
+	sf := h.SubscribeFilterE("test.filter", "my_callback", func(ctx context.Context, i ...interface{}) (interface{}, error) {
+		return i[0].(string) + "e", nil
+	}, 10)
+
+	var hookErr *hooks.HookError
+	if err := h.DoActionE("test.action"); !errors.As(err, &hookErr) {
+		t.Errorf("Expected %v to be a HookError", err)
+	}
+
+	if v, err := h.ApplyFiltersE("test.filter", "test"); v != "teste" || err != nil {
+		t.Errorf("Expected %v, %v to be equal to %v, nil", v, err, "teste")
+	}
+
+	if !sa.Remove() || !sf.Remove() {
+		t.Errorf("Expected callbacks to be removed.")
+	}
+
+	if err := h.DoActionE("test.action"); err != nil {
+		t.Errorf("Expected %v to be nil", err)
+	}
+}
+
+// Subscriptions move and disable a single callback
+func TestSubscriptionPriorityEnabled(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	sa := h.SubscribeFilter("test.filter", "my_callback", filterA, 1)
+	h.SubscribeFilter("test.filter", "my_callback", filterB, 2)
+	sc := h.SubscribeFilter("test.filter", "my_callback", filterC, 3)
+
+	sa.SetPriority(5)
+
+	expected := "testbca"
+	if v := h.ApplyFilters("test.filter", "test"); v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+
+	if !sc.Disable() || sc.Enabled() {
+		t.Errorf("Expected callback to be disabled.")
+	}
+
+	expected = "testba"
+	if v := h.ApplyFilters("test.filter", "test"); v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+
+	if !sc.Enable() || !sc.Enabled() {
+		t.Errorf("Expected callback to be enabled.")
+	}
+
+	// A callback can remove itself while running through its subscription.
+	var sd *hooks.Subscription
+	sd = h.SubscribeFilter("test.filter", "my_callback", func(i ...interface{}) interface{} {
+		sd.Remove()
+		return i[0].(string) + "d"
+	}, 4)
+
+	expected = "testbcda"
+	if v := h.ApplyFilters("test.filter", "test"); v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+
+	expected = "testbca"
+	if v := h.ApplyFilters("test.filter", "test"); v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+
+	if sa.SetPriority(1) != true || sd.SetPriority(1) != false {
+		t.Errorf("Expected only the added callback to be moved.")
+	}
+}
+
+// A filter callback returning Stop ends the chain with its value
+func TestStopFilter(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	h.AddFilter("test.filter", "my_callback_filter_a", filterA, 1)
+	h.AddFilter("test.filter", "my_callback_cache", func(i ...interface{}) interface{} {
+		if i[0] == "cachea" {
+			return hooks.Stop("cached")
+		}
+		return i[0]
+	}, 2)
+	h.AddFilter("test.filter", "my_callback_filter_c", filterC, 3)
+
+	expected := "cached"
+	if v := h.ApplyFilters("test.filter", "cache"); v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+
+	expected = "testac"
+	if v := h.ApplyFilters("test.filter", "test"); v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+
+	expected2 := 2
+	if v := h.DidFilter("test.filter"); v != expected2 {
+		t.Errorf("Expected %d to be equal to %d", v, expected2)
+	}
+
+	if h.DoingFilter("") {
+		t.Errorf("Expected filter to not be running.")
+	}
+}
+
+// An action callback calling StopAction ends the chain
+func TestStopAction(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	if err := h.StopAction(); err == nil {
+		t.Errorf("Expected an error when no action is running.")
+	}
+
+	h.AddAction("test.action", "my_callback_action_a", actionA, 1)
+	h.AddAction("test.action", "my_callback_deny", func(i ...interface{}) interface{} {
+		// Stopping a nested hook does not stop this one.
+		h.DoAction("another.action")
+
+		if hi, _ := h.CurrentAction(); hi.Stopped {
+			t.Errorf("Expected action to not be stopped yet.")
+		}
+
+		if err := h.StopAction(); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+
+		if hi, _ := h.CurrentAction(); !hi.Stopped {
+			t.Errorf("Expected action to be stopped.")
+		}
+		return nil
+	}, 2)
+	h.AddAction("test.action", "my_callback_action_c", actionC, 3)
+
+	h.AddAction("another.action", "my_callback_stop", func(i ...interface{}) interface{} {
+		h.StopAction()
+		return nil
+	}, 1)
+	h.AddAction("another.action", "my_callback_action_b", actionB, 2)
+
+	h.DoAction("test.action")
+
+	expected := "a"
+	if actionValue != expected {
+		t.Errorf("Expected %s to be equal to %s", actionValue, expected)
+	}
+
+	if h.DoingAction("") {
+		t.Errorf("Expected action to not be running.")
+	}
+}
+
+// Async actions run in the background until the pool is closed
+func TestAsyncPool(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var mu sync.Mutex
+	total := 0
+
+	h.AddAction("test.action", "my_callback", func(i ...interface{}) interface{} {
+		mu.Lock()
+		total += i[0].(int)
+		mu.Unlock()
+		return nil
+	}, 10)
+
+	pool := hooks.NewAsyncPool(h.Engine(), hooks.AsyncOptions{Workers: 4, QueueSize: 2})
+
+	for i := 1; i <= 10; i++ {
+		if err := pool.DoActionAsync("test.action", i); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	}
+
+	if err := pool.Close(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	expected := 55
+	if total != expected {
+		t.Errorf("Expected %d to be equal to %d", total, expected)
+	}
+
+	if err := pool.DoActionAsync("test.action", 1); !errors.Is(err, hooks.ErrPoolClosed) {
+		t.Errorf("Expected %v to be equal to %v", err, hooks.ErrPoolClosed)
+	}
+
+	expected = 10
+	if v := h.DidAction("test.action"); v != expected {
+		t.Errorf("Expected %d to be equal to %d", v, expected)
+	}
+}
+
+// Backpressure policies of a full async queue
+func TestAsyncPoolBackpressure(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var mu sync.Mutex
+	var ran []int
+	h.AddAction("test.action", "my_callback", func(i ...interface{}) interface{} {
+		if i[0] == 0 {
+			close(started)
+			<-release
+		}
+		mu.Lock()
+		ran = append(ran, i[0].(int))
+		mu.Unlock()
+		return nil
+	}, 10)
+
+	for _, tc := range []struct {
+		backpressure hooks.Backpressure
+		err          error
+		ran          []int
+		dropped      int
+	}{
+		{hooks.ErrorWhenFull, hooks.ErrQueueFull, []int{0, 1}, 0},
+		{hooks.DropNewest, nil, []int{0, 1}, 1},
+		{hooks.DropOldest, nil, []int{0, 2}, 1},
+	} {
+		started = make(chan struct{})
+		release = make(chan struct{})
+		ran = nil
+		dropped := 0
+
+		pool := hooks.NewAsyncPool(h.Engine(), hooks.AsyncOptions{
+			Workers:      1,
+			QueueSize:    1,
+			Backpressure: tc.backpressure,
+			OnError: func(hookName string, err error) {
+				if errors.Is(err, hooks.ErrQueueFull) {
+					dropped++
+				}
+			},
+		})
+
+		// Occupy the worker, then fill the queue.
+		pool.DoActionAsync("test.action", 0)
+		<-started
+		pool.DoActionAsync("test.action", 1)
+
+		if err := pool.DoActionAsync("test.action", 2); err != tc.err {
+			t.Errorf("Expected %v to be equal to %v", err, tc.err)
+		}
+
+		close(release)
+		pool.Close()
+
+		if !reflect.DeepEqual(ran, tc.ran) {
+			t.Errorf("Expected %v to be equal to %v", ran, tc.ran)
+		}
+
+		if dropped != tc.dropped {
+			t.Errorf("Expected %d to be equal to %d", dropped, tc.dropped)
+		}
+	}
+}
+
+// Draining an async pool gives up when the context is done
+func TestAsyncPoolDrainTimeout(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	release := make(chan struct{})
+	h.AddAction("test.action", "my_callback", func(i ...interface{}) interface{} {
+		<-release
+		return nil
+	}, 10)
+
+	pool := hooks.NewAsyncPool(h.Engine(), hooks.AsyncOptions{Workers: 1})
+	pool.DoActionAsync("test.action")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := pool.Drain(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v to be equal to %v", err, context.Canceled)
+	}
+
+	close(release)
+
+	if err := pool.Drain(context.Background()); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+// Parallel actions run their callbacks concurrently
+func TestParallelAction(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var all sync.WaitGroup
+	all.Add(3)
+
+	for _, v := range []string{"a", "b", "c"} {
+		value := v
+		h.AddAction("test.action", "my_callback_"+value, func(i ...interface{}) interface{} {
+			all.Done()
+
+			// Every callback waits for the others to have started.
+			done := make(chan struct{})
+			go func() {
+				all.Wait()
+				close(done)
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Errorf("Expected callbacks to run concurrently.")
+			}
+
+			if hi, err := h.CurrentAction(); err != nil || hi.Name != "test.action" {
+				t.Errorf("Expected current action to be test.action.")
+			}
+
+			return value
+		}, 10)
+	}
+
+	h.SetActionParallel("test.action", -1)
+
+	results, err := h.DoActionCollect(context.Background(), "test.action")
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	expected := []interface{}{"a", "b", "c"}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %v to be equal to %v", results, expected)
+	}
+
+	if h.DoingAction("") {
+		t.Errorf("Expected action to not be running.")
+	}
+}
+
+// Parallel actions respect their concurrency limit and aggregate errors
+func TestParallelActionLimit(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var running, maxRunning int32
+
+	for i := 0; i < 6; i++ {
+		fail := i%2 == 0
+		h.AddActionE("test.action", fmt.Sprintf("my_callback_%d", i), func(ctx context.Context, i ...interface{}) (interface{}, error) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+
+			if fail {
+				return nil, errors.New("failed")
+			}
+			return nil, nil
+		}, i)
+	}
+
+	h.SetActionParallel("test.action", 2)
+	h.SetActionErrorPolicy("test.action", hooks.ContinueOnError)
+
+	err := h.DoActionE("test.action")
+
+	var hookErr *hooks.HookError
+	if !errors.As(err, &hookErr) {
+		t.Errorf("Expected %v to be a HookError", err)
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 3 {
+		t.Errorf("Expected 3 errors, got %v", err)
+	}
+
+	expected := int32(2)
+	if maxRunning != expected {
+		t.Errorf("Expected %d to be equal to %d", maxRunning, expected)
+	}
+
+	// Back to sequential dispatch.
+	h.SetActionParallel("test.action", 0)
+	maxRunning = 0
+	h.DoActionE("test.action")
+
+	expected = 1
+	if maxRunning != expected {
+		t.Errorf("Expected %d to be equal to %d", maxRunning, expected)
+	}
+}
+
+// StopAction stops a parallel action from any of its callbacks
+func TestParallelActionStop(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var ran []string
+	h.AddAction("test.action", "my_callback_stop", func(i ...interface{}) interface{} {
+		ran = append(ran, "stop")
+		if err := h.StopAction(); err != nil {
+			t.Error(err)
+		}
+		return nil
+	}, 10)
+	h.AddAction("test.action", "my_callback_action", func(i ...interface{}) interface{} {
+		ran = append(ran, "action")
+		return nil
+	}, 11)
+
+	// One callback at a time, so that the first one stops the action before
+	// the second one starts.
+	h.SetActionParallel("test.action", 1)
+	h.DoAction("test.action")
+
+	expected := []string{"stop"}
+	if !reflect.DeepEqual(ran, expected) {
+		t.Errorf("Expected %v to be equal to %v", ran, expected)
+	}
+}
+
+// Parallel actions raise the panics of their callbacks again under PropagatePanic
+func TestParallelActionPanicPropagates(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	failed := 0
+	h.AddAction("HookFailed", "my_callback_failed", func(i ...interface{}) interface{} {
+		failed++
+		return nil
+	}, 10)
+
+	h.AddAction("test.action", "my_callback_panics", func(i ...interface{}) interface{} {
+		panic("boom")
+	}, 10)
+	h.AddAction("test.action", "my_callback_action", func(i ...interface{}) interface{} {
+		return nil
+	}, 11)
+
+	h.SetActionParallel("test.action", -1)
+
+	func() {
+		defer func() {
+			panicErr, ok := recover().(*hooks.PanicError)
+			if !ok {
+				t.Errorf("Expected %v to be a PanicError", panicErr)
+				return
+			}
+
+			if panicErr.Value != "boom" || panicErr.Namespace != "my_callback_panics" {
+				t.Errorf("Expected %v to be equal to %s", panicErr.Value, "boom")
+			}
+
+			if !strings.Contains(string(panicErr.Stack), "TestParallelActionPanicPropagates") {
+				t.Errorf("Expected the stack of the callback, got %s", panicErr.Stack)
+			}
+		}()
+		h.DoAction("test.action")
+	}()
+
+	expected := 0
+	if failed != expected {
+		t.Errorf("Expected %d to be equal to %d", failed, expected)
+	}
+}
+
+// Ordering constraints win over priorities
+func TestAddFilterOrdered(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	h.AddFilter("test.filter", "vendor/seo/title", filterA, 10)
+	h.AddFilter("test.filter", "vendor/cache/read", filterB, 1)
+	h.AddFilter("test.filter", "vendor/cache/write", filterC, 20)
+
+	// Runs after the title although its priority is lower, and before
+	// every cache callback although one of them has a lower priority.
+	err := h.AddFilterOrdered("test.filter", "vendor/plugin/d", func(i ...interface{}) interface{} {
+		return i[0].(string) + "d"
+	}, 5, hooks.Order{
+		After:  []string{"vendor/seo/title"},
+		Before: []string{"vendor/cache/*"},
+	})
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	expected := "testadbc"
+	if v := h.ApplyFilters("test.filter", "test"); v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+
+	// Callbacks added later are still placed according to the constraints.
+	h.AddFilter("test.filter", "vendor/cache/purge", func(i ...interface{}) interface{} {
+		return i[0].(string) + "p"
+	}, 0)
+
+	expected = "testadpbc"
+	if v := h.ApplyFilters("test.filter", "test"); v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+}
+
+// Contradicting ordering constraints are rejected
+func TestAddActionOrderedCycle(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	added := 0
+	h.AddAction("HookAdded", "my_callback", func(i ...interface{}) interface{} {
+		added++
+		return nil
+	}, 10)
+
+	err := h.AddActionOrdered("test.action", "vendor/plugin/a", actionA, 10, hooks.Order{
+		Before: []string{"vendor/plugin/b"},
+	})
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	err = h.AddActionOrdered("test.action", "vendor/plugin/b", actionB, 10, hooks.Order{
+		Before: []string{"vendor/plugin/a"},
+	})
+	if !errors.Is(err, hooks.ErrOrderCycle) {
+		t.Errorf("Expected %v to be equal to %v", err, hooks.ErrOrderCycle)
+	}
+
+	err = h.AddActionOrdered("test.action", "vendor/plugin/c", actionC, 10, hooks.Order{
+		After:  []string{"vendor/plugin/a"},
+		Before: []string{"vendor/plugin/a"},
+	})
+	if !errors.Is(err, hooks.ErrOrderCycle) {
+		t.Errorf("Expected %v to be equal to %v", err, hooks.ErrOrderCycle)
+	}
+
+	h.DoAction("test.action")
+
+	expected := "a"
+	if actionValue != expected {
+		t.Errorf("Expected %s to be equal to %s", actionValue, expected)
+	}
+
+	if added != 1 {
+		t.Errorf("Expected HookAdded to be triggered once.")
+	}
+}
+
+// Callbacks completing a cycle of ordering constraints are rejected
+func TestAddFilterCompletesOrderCycle(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var failed []error
+	h.AddAction("HookFailed", "my_callback", func(i ...interface{}) interface{} {
+		failed = append(failed, i[3].(error))
+		return nil
+	}, 10)
+
+	appendFilter := func(s string) func(...interface{}) interface{} {
+		return func(i ...interface{}) interface{} {
+			return i[0].(string) + s
+		}
+	}
+
+	h.AddFilterOrdered("test.filter", "vendor/plugin/a", appendFilter("a"), 10, hooks.Order{
+		After: []string{"vendor/plugin/p"},
+	})
+	h.AddFilterOrdered("test.filter", "vendor/plugin/b", appendFilter("b"), 10, hooks.Order{
+		After:  []string{"vendor/plugin/a"},
+		Before: []string{"vendor/plugin/p"},
+	})
+	h.AddFilter("test.filter", "vendor/plugin/p", appendFilter("p"), 10)
+	h.AddFilterPattern("test.*", "vendor/plugin/p", appendFilter("p"), 10)
+
+	expected := "testab"
+	if v := h.ApplyFilters("test.filter", "test"); v != expected {
+		t.Errorf("Expected %s to be equal to %s", v, expected)
+	}
+
+	if len(failed) != 2 || !errors.Is(failed[0], hooks.ErrOrderCycle) || !errors.Is(failed[1], hooks.ErrOrderCycle) {
+		t.Errorf("Expected %v to be equal to %v", failed, hooks.ErrOrderCycle)
+	}
+
+	handlers := h.FilterHandlers("test.filter")
+	if len(handlers) != 2 {
+		t.Errorf("Expected %d to be equal to %d", len(handlers), 2)
+	}
+}
+
+// Remove, suspend and resume the callbacks of a plugin across every hook
+func TestNamespacePrefix(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var removed []string
+	h.AddAction("HookRemoved", "my_callback", func(i ...interface{}) interface{} {
+		removed = append(removed, i[0].(string)+" "+i[1].(string))
+		return nil
+	}, 10)
+
+	h.AddAction("test.action", "vendor/plugin/a", actionA, 10)
+	h.AddAction("another.action", "vendor/plugin/sub/b", actionB, 10)
+	h.AddAction("test.action", "vendor/other/c", actionC, 11)
+	h.AddFilter("test.filter", "vendor/plugin/a", filterA, 10)
+	h.AddFilter("test.filter", "vendor/other/c", filterC, 11)
+
+	sb := h.SubscribeAction("test.action", "vendor/plugin/b", actionB, 12)
+	sb.Disable()
+
+	expected := 4
+	if v := h.SuspendNamespace("vendor/plugin/*"); v != expected {
+		t.Errorf("Expected %d to be equal to %d", v, expected)
+	}
+
+	h.DoAction("test.action")
+	h.DoAction("another.action")
+
+	expected2 := "testc"
+	if v := h.ApplyFilters("test.filter", "test"); v != expected2 {
+		t.Errorf("Expected %s to be equal to %s", v, expected2)
+	}
+
+	if v := h.ResumeNamespace("vendor/plugin/*"); v != expected {
+		t.Errorf("Expected %d to be equal to %d", v, expected)
+	}
+
+	// Resuming does not enable a callback disabled through its subscription.
+	if sb.Enabled() {
+		t.Errorf("Expected callback to stay disabled.")
+	}
+
+	h.DoAction("test.action")
+	h.DoAction("another.action")
+
+	expected = 4
+	if v := h.RemoveNamespace("vendor/plugin/*"); v != expected {
+		t.Errorf("Expected %d to be equal to %d", v, expected)
+	}
+
+	h.DoAction("test.action")
+
+	expected2 = "cacbc"
+	if actionValue != expected2 {
+		t.Errorf("Expected %s to be equal to %s", actionValue, expected2)
+	}
+
+	expected3 := []string{
+		"another.action vendor/plugin/sub/b",
+		"test.action vendor/plugin/b",
+		"test.action vendor/plugin/a",
+		"test.filter vendor/plugin/a",
+	}
+	if !reflect.DeepEqual(removed, expected3) {
+		t.Errorf("Expected %v to be equal to %v", removed, expected3)
+	}
+}
+
+// Pattern callbacks run for every matching hook, in priority order
+func TestAddActionPattern(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var fired []string
+	record := func(i ...interface{}) interface{} {
+		hi, _ := h.CurrentAction()
+		fired = append(fired, hi.Name)
+		return nil
+	}
+
+	h.AddAction("save_post_page", "my_callback_action_a", actionA, 5)
+	h.AddActionPattern("save_post_*", "my_callback_pattern", func(i ...interface{}) interface{} {
+		actionValue += "p"
+		return nil
+	}, 1)
+	h.AddActionPattern("http.request.*", "my_callback_segment", record, 10)
+	h.AddActionPattern("http.#", "my_callback_segments", record, 20)
+
+	h.DoAction("save_post_page")
+	h.DoAction("save_post_product")
+	h.DoAction("save_post")
+
+	expected := "pap"
+	if actionValue != expected {
+		t.Errorf("Expected %s to be equal to %s", actionValue, expected)
+	}
+
+	h.DoAction("http.request.get")
+	h.DoAction("http.request.get.slow")
+	h.DoAction("http")
+
+	expected2 := []string{"http.request.get", "http.request.get", "http.request.get.slow", "http"}
+	if !reflect.DeepEqual(fired, expected2) {
+		t.Errorf("Expected %v to be equal to %v", fired, expected2)
+	}
+
+	expected3 := 1
+	if v := h.RemoveActionPattern("save_post_*", "my_callback_pattern"); v != expected3 {
+		t.Errorf("Expected %d to be equal to %d", v, expected3)
+	}
+
+	h.DoAction("save_post_page")
+	h.DoAction("save_post_other")
+
+	expected = "papa"
+	if actionValue != expected {
+		t.Errorf("Expected %s to be equal to %s", actionValue, expected)
+	}
+
+	// Removing a namespace also removes its pattern callbacks.
+	h.RemoveNamespace("my_callback_segment*")
+	fired = nil
+	h.DoAction("http.request.post")
+
+	if len(fired) != 0 {
+		t.Errorf("Expected %v to be empty", fired)
+	}
+}
+
+// The all hook observes every action and filter
+func TestAllHook(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var observed []string
+	h.AddAction("all", "my_callback", func(i ...interface{}) interface{} {
+		args := i[2].([]interface{})
+		observed = append(observed, fmt.Sprintf("%s %s %v", i[1], i[0], args))
+		return nil
+	}, 10)
+
+	h.AddFilter("test.filter", "my_callback_filter_a", filterA, 10)
+	h.AddAction("test.action", "my_callback_action_a", actionA, 10)
+	h.RemoveAction("test.action", "my_callback_action_a")
+
+	h.ApplyFilters("test.filter", "test", 1)
+	h.DoAction("test.action", 2)
+	h.DoAction("HookFailed")
+
+	expected := []string{"filter test.filter [test 1]", "action test.action [2]"}
+	if !reflect.DeepEqual(observed, expected) {
+		t.Errorf("Expected %v to be equal to %v", observed, expected)
+	}
+
+	if v := h.DidAction("all"); v != len(expected) {
+		t.Errorf("Expected %d to be equal to %d", v, len(expected))
+	}
+}
+
+type testPlugin struct {
+	name        string
+	version     string
+	activate    func(core *hooks.Core) error
+	deactivated bool
+}
+
+func (p *testPlugin) Name() string    { return p.name }
+func (p *testPlugin) Version() string { return p.version }
+
+func (p *testPlugin) Activate(core *hooks.Core) error {
+	return p.activate(core)
+}
+
+func (p *testPlugin) Deactivate(core *hooks.Core) error {
+	p.deactivated = true
+	return nil
+}
+
+// Deactivating a plugin removes the callbacks it added
+func TestPluginManager(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var lifecycle []string
+	h.AddAction("plugin_activated", "my_callback", func(i ...interface{}) interface{} {
+		lifecycle = append(lifecycle, fmt.Sprintf("activated %s %s", i[0], i[1]))
+		return nil
+	}, 10)
+	h.AddAction("plugin_deactivated", "my_callback", func(i ...interface{}) interface{} {
+		lifecycle = append(lifecycle, fmt.Sprintf("deactivated %s %s", i[0], i[1]))
+		return nil
+	}, 10)
+
+	h.AddFilter("test.filter", "my_callback_filter_a", filterA, 10)
+
+	seo := &testPlugin{name: "seo", version: "1.0.0", activate: func(core *hooks.Core) error {
+		core.AddFilter("test.filter", "seo/filter_b", filterB, 11)
+		core.AddActionPattern("test.#", "seo/action_a", actionA, 10)
+		return nil
+	}}
+	cache := &testPlugin{name: "cache", version: "2.0.0", activate: func(core *hooks.Core) error {
+		core.AddFilter("test.filter", "cache/filter_c", filterC, 12)
+		return nil
+	}}
+
+	pm := hooks.NewPluginManager(h.Engine())
+	if err := pm.Activate(seo, cache); err != nil {
+		t.Fatalf("Expected %v to be nil", err)
+	}
+
+	if err := pm.Activate(seo); !errors.Is(err, hooks.ErrPluginActive) {
+		t.Errorf("Expected %v to be %v", err, hooks.ErrPluginActive)
+	}
+
+	expectedPlugins := []hooks.PluginInfo{{Name: "seo", Version: "1.0.0"}, {Name: "cache", Version: "2.0.0"}}
+	if v := pm.Plugins(); !reflect.DeepEqual(v, expectedPlugins) {
+		t.Errorf("Expected %v to be equal to %v", v, expectedPlugins)
+	}
+
+	if v := h.ApplyFilters("test.filter", ""); v != "abc" {
+		t.Errorf("Expected %v to be equal to %v", v, "abc")
+	}
+
+	if err := pm.Deactivate("seo"); err != nil {
+		t.Fatalf("Expected %v to be nil", err)
+	}
+
+	if !seo.deactivated || pm.IsActive("seo") || !pm.IsActive("cache") {
+		t.Errorf("Expected only seo to be deactivated")
+	}
+
+	if v := h.ApplyFilters("test.filter", ""); v != "ac" {
+		t.Errorf("Expected %v to be equal to %v", v, "ac")
+	}
+
+	h.DoAction("test.action")
+	if actionValue != "" {
+		t.Errorf("Expected %v to be empty", actionValue)
+	}
+
+	if err := pm.Deactivate("seo"); !errors.Is(err, hooks.ErrPluginNotFound) {
+		t.Errorf("Expected %v to be %v", err, hooks.ErrPluginNotFound)
+	}
+
+	if err := pm.DeactivateAll(); err != nil {
+		t.Fatalf("Expected %v to be nil", err)
+	}
+
+	if v := h.ApplyFilters("test.filter", ""); v != "a" {
+		t.Errorf("Expected %v to be equal to %v", v, "a")
+	}
+
+	expected := []string{"activated seo 1.0.0", "activated cache 2.0.0", "deactivated seo 1.0.0", "deactivated cache 2.0.0"}
+	if !reflect.DeepEqual(lifecycle, expected) {
+		t.Errorf("Expected %v to be equal to %v", lifecycle, expected)
+	}
+}
+
+// A plugin failing to activate leaves no callbacks behind
+func TestPluginActivateError(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	errBroken := errors.New("broken")
+	broken := &testPlugin{name: "broken", version: "1.0.0", activate: func(core *hooks.Core) error {
+		core.AddFilter("test.filter", "broken/filter_a", filterA, 10)
+		return errBroken
+	}}
+	never := &testPlugin{name: "never", version: "1.0.0", activate: func(core *hooks.Core) error {
+		core.AddFilter("test.filter", "never/filter_b", filterB, 10)
+		return nil
+	}}
+
+	pm := hooks.NewPluginManager(h.Engine())
+	if err := pm.Activate(broken, never); !errors.Is(err, errBroken) {
+		t.Errorf("Expected %v to be %v", err, errBroken)
+	}
+
+	if pm.IsActive("broken") || pm.IsActive("never") {
+		t.Errorf("Expected no plugin to be active")
+	}
+
+	if v := h.ApplyFilters("test.filter", ""); v != "" {
+		t.Errorf("Expected %v to be empty", v)
+	}
+}
+
+type testDependentPlugin struct {
+	testPlugin
+	dependencies []hooks.Dependency
+}
+
+func (p *testDependentPlugin) Dependencies() []hooks.Dependency {
+	return p.dependencies
+}
+
+// Plugins are activated after the plugins they depend on
+func TestPluginDependencies(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var lifecycle []string
+	h.AddAction("plugin_activated", "my_callback", func(i ...interface{}) interface{} {
+		lifecycle = append(lifecycle, fmt.Sprintf("activated %s", i[0]))
+		return nil
+	}, 10)
+	h.AddAction("plugin_deactivated", "my_callback", func(i ...interface{}) interface{} {
+		lifecycle = append(lifecycle, fmt.Sprintf("deactivated %s", i[0]))
+		return nil
+	}, 10)
+
+	activate := func(core *hooks.Core) error { return nil }
+	accounts := &testPlugin{name: "accounts", version: "2.3.1", activate: activate}
+	billing := &testDependentPlugin{
+		testPlugin:   testPlugin{name: "billing", version: "1.0.0", activate: activate},
+		dependencies: []hooks.Dependency{{Name: "accounts", Constraint: ">= 2.1, <3"}},
+	}
+	invoices := &testDependentPlugin{
+		testPlugin:   testPlugin{name: "invoices", version: "1.0.0", activate: activate},
+		dependencies: []hooks.Dependency{{Name: "billing", Constraint: "^1.0"}},
+	}
+	reports := &testPlugin{name: "reports", version: "1.0.0", activate: activate}
+
+	pm := hooks.NewPluginManager(h.Engine())
+	if err := pm.Activate(invoices, billing, reports, accounts); err != nil {
+		t.Fatalf("Expected %v to be nil", err)
+	}
+
+	if err := pm.Deactivate("accounts"); err != nil {
+		t.Fatalf("Expected %v to be nil", err)
+	}
+
+	if !pm.IsActive("reports") || pm.IsActive("billing") {
+		t.Errorf("Expected only reports to stay active")
+	}
+
+	expected := []string{
+		"activated accounts", "activated billing", "activated invoices", "activated reports",
+		"deactivated invoices", "deactivated billing", "deactivated accounts",
+	}
+	if !reflect.DeepEqual(lifecycle, expected) {
+		t.Errorf("Expected %v to be equal to %v", lifecycle, expected)
+	}
+}
+
+// Plugins with missing, incompatible or cyclic dependencies are not activated
+func TestPluginDependencyErrors(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	activate := func(core *hooks.Core) error { return nil }
+	dependent := func(name string, deps ...hooks.Dependency) hooks.Plugin {
+		return &testDependentPlugin{
+			testPlugin:   testPlugin{name: name, version: "1.0.0", activate: activate},
+			dependencies: deps,
+		}
+	}
+	accounts := &testPlugin{name: "accounts", version: "2.0.0", activate: activate}
+
+	tests := []struct {
+		plugins  []hooks.Plugin
+		expected error
+	}{
+		{[]hooks.Plugin{dependent("billing", hooks.Dependency{Name: "accounts"})}, hooks.ErrMissingDependency},
+		{[]hooks.Plugin{accounts, dependent("billing", hooks.Dependency{Name: "accounts", Constraint: ">=2.1"})}, hooks.ErrIncompatibleDependency},
+		{[]hooks.Plugin{accounts, dependent("billing", hooks.Dependency{Name: "accounts", Constraint: "~1.9"})}, hooks.ErrIncompatibleDependency},
+		{[]hooks.Plugin{dependent("a", hooks.Dependency{Name: "b"}), dependent("b", hooks.Dependency{Name: "a"})}, hooks.ErrDependencyCycle},
+	}
+
+	pm := hooks.NewPluginManager(h.Engine())
+	for _, test := range tests {
+		if err := pm.Activate(test.plugins...); !errors.Is(err, test.expected) {
+			t.Errorf("Expected %v to be %v", err, test.expected)
+		}
+
+		if v := pm.Plugins(); len(v) != 0 {
+			t.Errorf("Expected %v to be empty", v)
+		}
+	}
+
+	if err := pm.Activate(accounts, dependent("billing", hooks.Dependency{Name: "accounts", Constraint: "^2.0.0-beta, !=2.0.1"})); err != nil {
+		t.Errorf("Expected %v to be nil", err)
+	}
+}
+
+// pluginLoaded is set once TestPluginLoad loaded its plugin, which Go cannot
+// load again from another file.
+var pluginLoaded bool
+
+// Plugins are loaded from shared objects
+func TestPluginLoad(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	pm := hooks.NewPluginManager(h.Engine())
+	if _, err := pm.Load("testdata/missing.so", ""); errors.Is(err, hooks.ErrPluginsUnsupported) {
+		t.Skip(err)
+	} else if err == nil {
+		t.Fatalf("Expected an error")
+	}
+
+	if testing.Short() {
+		t.Skip("skipping plugin build in short mode")
+	}
+	if pluginLoaded {
+		t.Skip("plugin already loaded by a previous run")
+	}
+
+	// The plugin must be built with the same settings as the test, or it
+	// would not share its packages.
+	args := []string{"build", "-buildmode=plugin"}
+	env := os.Environ()
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "-race", "-msan", "-asan", "-trimpath":
+				if setting.Value == "true" {
+					args = append(args, setting.Key)
+				}
+			case "-cover":
+				if setting.Value == "true" {
+					t.Skip("skipping plugin build with coverage")
+				}
+			case "-tags":
+				args = append(args, "-tags="+setting.Value)
+			case "-gcflags", "-asmflags":
+				// Flags without a package pattern only applied to the
+				// package under test.
+				value := setting.Value
+				if strings.HasPrefix(value, "-") {
+					value = "github.com/Golang-Hooks/Golang-Hooks=" + value
+				}
+				args = append(args, setting.Key+"="+value)
+			case "CGO_ENABLED", "GOEXPERIMENT", "GOAMD64", "GOARM", "GOARM64", "GO386", "GOMIPS", "GOMIPS64", "GOPPC64", "GORISCV64", "GOWASM":
+				env = append(env, setting.Key+"="+setting.Value)
+			}
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "soplugin.so")
+	args = append(args, "-o", path, "./testdata/soplugin")
+	cmd := exec.Command("go", args...)
+	cmd.Env = env
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+
+	if _, err := pm.Load(path, ">=2"); !errors.Is(err, hooks.ErrPluginVersion) {
+		t.Errorf("Expected %v to be %v", err, hooks.ErrPluginVersion)
+	}
+
+	p, err := pm.Load(path, "^1.0")
+	if err != nil {
+		t.Fatalf("Expected %v to be nil", err)
+	}
+	pluginLoaded = true
+
+	expected := []hooks.PluginInfo{{Name: p.Name(), Version: "1.2.0", Path: path}}
+	if v := pm.Plugins(); !reflect.DeepEqual(v, expected) {
+		t.Errorf("Expected %v to be equal to %v", v, expected)
+	}
+
+	if v := h.ApplyFilters("the_title", "Hello"); v != "Hello!" {
+		t.Errorf("Expected %v to be equal to %v", v, "Hello!")
+	}
+
+	if v := h.RemoveNamespace("vendor/title/*"); v != 1 {
+		t.Errorf("Expected %d to be equal to %d", v, 1)
+	}
+}
+
+// processPlugin is served by the test binary itself when started by
+// TestProcessPlugin.
+var processPlugin = &testPlugin{name: "vendor/process", version: "1.0.0", activate: func(core *hooks.Core) error {
+	core.AddFilter("test.filter", "vendor/process/filter", func(i ...interface{}) interface{} {
+		return fmt.Sprintf("%v%v", i[0], i[1])
+	}, 10)
+	core.AddAction("test.crash", "vendor/process/crash", func(i ...interface{}) interface{} {
+		os.Exit(2)
+		return nil
+	}, 10)
+	core.AddAction("test.sleep", "vendor/process/sleep", func(i ...interface{}) interface{} {
+		time.Sleep(time.Second)
+		return nil
+	}, 10)
+	return nil
+}}
+
+func TestProcessPluginHelper(t *testing.T) {
+	switch os.Getenv("HOOKS_PROCESS_PLUGIN") {
+	case "1":
+	case "stuck":
+		// Answer the handshake, then stop reading the standard input.
+		line, _ := bufio.NewReader(os.Stdin).ReadBytes('\n')
+		r, w, _ := os.Pipe()
+		w.Write(line)
+		os.Stdin = r
+	default:
+		t.Skip("only run as the process of TestProcessPlugin")
+	}
+
+	if err := hooks.ServeProcessPlugin(processPlugin); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// Process plugins run their callbacks in a child process
+func TestProcessPlugin(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var events []string
+	exited := make(chan struct{})
+	h.AddAction("plugin_process_restarted", "my_callback", func(i ...interface{}) interface{} {
+		events = append(events, fmt.Sprintf("restarted %s %d", i[0], i[1]))
+		return nil
+	}, 10)
+	h.AddAction("plugin_process_exited", "my_callback", func(i ...interface{}) interface{} {
+		events = append(events, fmt.Sprintf("exited %s", i[0]))
+		close(exited)
+		return nil
+	}, 10)
+
+	// The proxies can run as soon as they are added.
+	core := h
+	added := make(chan interface{}, 2)
+	h.AddAction("HookAdded", "my_callback", func(i ...interface{}) interface{} {
+		if i[0] == "test.filter" && i[1] == "vendor/process/filter" {
+			added <- core.ApplyFilters("test.filter", "x", 0)
+		}
+		return nil
+	}, 10)
+
+	p, err := hooks.StartProcessPlugin(hooks.ProcessOptions{
+		Command:     os.Args[0],
+		Args:        []string{"-test.run=^TestProcessPluginHelper$"},
+		Env:         []string{"HOOKS_PROCESS_PLUGIN=1"},
+		CallTimeout: 200 * time.Millisecond,
+		MaxRestarts: 1,
+	})
+	if err != nil {
+		t.Fatalf("Expected %v to be nil", err)
+	}
+
+	// The proxies are added through the Registry, even when it is
+	// decorated.
+	pm := hooks.NewPluginManager(countingDispatcher{h.Engine(), map[string]int{}})
+	if err := pm.Activate(p); err != nil {
+		t.Fatalf("Expected %v to be nil", err)
+	}
+
+	if v := <-added; v != "x0" {
+		t.Errorf("Expected %v to be equal to %v", v, "x0")
+	}
+
+	if v := h.ApplyFilters("test.filter", "a", 1); v != "a1" {
+		t.Errorf("Expected %v to be equal to %v", v, "a1")
+	}
+
+	if err := h.DoActionE("test.sleep"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected %v to be %v", err, context.DeadlineExceeded)
+	}
+
+	// The process is started again after the first crash.
+	if err := h.DoActionE("test.crash"); !errors.Is(err, hooks.ErrProcessExited) {
+		t.Errorf("Expected %v to be %v", err, hooks.ErrProcessExited)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for h.ApplyFilters("test.filter", "b", 2) != "b2" {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the process to restart")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The proxies are removed after the second one.
+	h.DoActionE("test.crash")
+
+	select {
+	case <-exited:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Expected the process to exit")
+	}
+
+	if v := h.ApplyFilters("test.filter", "c", 3); v != "c" {
+		t.Errorf("Expected %v to be equal to %v", v, "c")
+	}
+
+	expected := []string{"restarted vendor/process 1", "exited vendor/process"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected %v to be equal to %v", events, expected)
+	}
+
+	if err := pm.Deactivate("vendor/process"); err != nil {
+		t.Errorf("Expected %v to be nil", err)
+	}
+}
+
+// Calls to a process which stops reading its requests give up in time
+func TestProcessPluginStuck(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	p, err := hooks.StartProcessPlugin(hooks.ProcessOptions{
+		Command:     os.Args[0],
+		Args:        []string{"-test.run=^TestProcessPluginHelper$"},
+		Env:         []string{"HOOKS_PROCESS_PLUGIN=stuck"},
+		CallTimeout: 200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Expected %v to be nil", err)
+	}
+
+	pm := hooks.NewPluginManager(h.Engine())
+	if err := pm.Activate(p); err != nil {
+		t.Fatalf("Expected %v to be nil", err)
+	}
+	defer pm.Deactivate("vendor/process")
+
+	// The request does not fit in the buffer of the pipe.
+	large := strings.Repeat("x", 1<<20)
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := h.ApplyFiltersE("test.filter", large, 1)
+			errs <- err
+		}()
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			// The process is stopped once a request was partly written.
+			if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, hooks.ErrProcessExited) {
+				t.Errorf("Expected %v to be %v", err, context.DeadlineExceeded)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected the calls to give up")
+		}
+	}
+}
+
+// Callbacks running out of time are skipped or abort their hook
+func TestHookTimeouts(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	release := make(chan struct{})
+	defer close(release)
+
+	var timedOut []string
+	h.AddAction("HookTimedOut", "my_callback", func(i ...interface{}) interface{} {
+		err := i[3].(*hooks.TimeoutError)
+		timedOut = append(timedOut, fmt.Sprintf("%s %s %v", i[0], i[1], errors.Is(err, context.DeadlineExceeded)))
+		return nil
+	}, 10)
+
+	// The slow callbacks outlive the test, which replaces h when it ends.
+	core := h
+	current := make(chan string, 3)
+	h.AddFilter("test.filter", "my_callback_filter_a", filterA, 10)
+	h.AddFilter("test.filter", "my_callback_slow", func(i ...interface{}) interface{} {
+		info, _ := core.CurrentFilter()
+		current <- info.Name
+		<-release
+		return "slow"
+	}, 11)
+	h.AddFilter("test.filter", "my_callback_filter_c", filterC, 12)
+
+	h.SetFilterTimeout("test.filter", hooks.Timeouts{Callback: 20 * time.Millisecond})
+
+	v, err := h.ApplyFiltersE("test.filter", "")
+	if v != "ac" || err != nil {
+		t.Errorf("Expected %v, %v to be equal to %v, nil", v, err, "ac")
+	}
+
+	if v := <-current; v != "test.filter" {
+		t.Errorf("Expected %v to be equal to %v", v, "test.filter")
+	}
+
+	h.SetFilterTimeout("test.filter", hooks.Timeouts{Callback: 20 * time.Millisecond, Policy: hooks.AbortOnTimeout})
+
+	v, err = h.ApplyFiltersE("test.filter", "")
+	var timeoutErr *hooks.TimeoutError
+	if v != "a" || !errors.As(err, &timeoutErr) || timeoutErr.Namespace != "my_callback_slow" {
+		t.Errorf("Expected %v, %v to be equal to %v and a timeout of my_callback_slow", v, err, "a")
+	}
+
+	// A namespace timeout replaces the timeout of the hook.
+	h.SetFilterTimeout("test.filter", hooks.Timeouts{})
+	h.SetNamespaceTimeout("my_callback_slow", 20*time.Millisecond)
+
+	if v := h.ApplyFilters("test.filter", ""); v != "ac" {
+		t.Errorf("Expected %v to be equal to %v", v, "ac")
+	}
+
+	expected := []string{
+		"test.filter my_callback_slow true",
+		"test.filter my_callback_slow true",
+		"test.filter my_callback_slow true",
+	}
+	if !reflect.DeepEqual(timedOut, expected) {
+		t.Errorf("Expected %v to be equal to %v", timedOut, expected)
+	}
+}
+
+// A panic in a timed callback reaches the caller with the stack of the callback
+func TestHookTimeoutPanicPropagates(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	h.AddAction("test.action", "my_callback_panics", func(i ...interface{}) interface{} {
+		panic("boom")
+	}, 10)
+
+	h.SetActionTimeout("test.action", hooks.Timeouts{Callback: time.Second})
+
+	defer func() {
+		panicErr, ok := recover().(*hooks.PanicError)
+		if !ok {
+			t.Errorf("Expected %v to be a PanicError", panicErr)
+			return
+		}
+
+		if panicErr.Value != "boom" || panicErr.Namespace != "my_callback_panics" {
+			t.Errorf("Expected %v to be equal to %s", panicErr.Value, "boom")
+		}
+
+		if !strings.Contains(string(panicErr.Stack), "TestHookTimeoutPanicPropagates") {
+			t.Errorf("Expected the stack of the callback, got %s", panicErr.Stack)
+		}
+	}()
+	h.DoAction("test.action")
+}
+
+// A callback which timed out no longer sees the hook it was called by
+func TestHookTimeoutStack(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	// The slow callback outlives the run of its hook.
+	core := h
+	release := make(chan struct{})
+	stacks := make(chan []hooks.HookInfo)
+	stopped := make(chan error)
+	h.AddAction("test.action", "my_callback_slow", func(i ...interface{}) interface{} {
+		stacks <- core.Stack()
+		<-release
+		stacks <- core.Stack()
+		stopped <- core.StopAction()
+		return nil
+	}, 10)
+
+	h.SetActionTimeout("test.action", hooks.Timeouts{Callback: 20 * time.Millisecond})
+
+	done := make(chan struct{})
+	go func() {
+		h.DoAction("test.action")
+		close(done)
+	}()
+
+	if stack := <-stacks; len(stack) != 1 || stack[0].Name != "test.action" {
+		t.Errorf("Expected %v to hold %v", stack, "test.action")
+	}
+
+	<-done
+	if h.DoingAction("test.action") {
+		t.Errorf("Expected %v to be equal to %v", true, false)
+	}
+
+	close(release)
+	if stack := <-stacks; len(stack) != 0 {
+		t.Errorf("Expected %v to be empty", stack)
+	}
+	if err := <-stopped; err == nil {
+		t.Errorf("Expected an error, got %v", err)
+	}
+}
+
+// The callbacks of a hook share its budget
+func TestHookBudget(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var timedOut []string
+	h.AddAction("HookTimedOut", "my_callback", func(i ...interface{}) interface{} {
+		timedOut = append(timedOut, fmt.Sprintf("%s %q", i[0], i[1]))
+		return nil
+	}, 10)
+
+	// Callbacks which timed out keep running in the background.
+	var mu sync.Mutex
+	var ran []string
+	for _, namespace := range []string{"my_callback_a", "my_callback_b", "my_callback_c"} {
+		namespace := namespace
+		h.AddAction("test.action", namespace, func(i ...interface{}) interface{} {
+			mu.Lock()
+			ran = append(ran, namespace)
+			mu.Unlock()
+			time.Sleep(40 * time.Millisecond)
+			return nil
+		}, 10)
+	}
+
+	h.SetActionTimeout("", hooks.Timeouts{Budget: 60 * time.Millisecond})
+
+	if err := h.DoActionE("test.action"); err != nil {
+		t.Errorf("Expected %v to be nil", err)
+	}
+
+	mu.Lock()
+	expectedRan := []string{"my_callback_a", "my_callback_b"}
+	if !reflect.DeepEqual(ran, expectedRan) {
+		t.Errorf("Expected %v to be equal to %v", ran, expectedRan)
+	}
+	mu.Unlock()
+
+	expected := []string{`test.action "my_callback_b"`, `test.action ""`}
+	if !reflect.DeepEqual(timedOut, expected) {
+		t.Errorf("Expected %v to be equal to %v", timedOut, expected)
+	}
+
+	h.SetActionTimeout("", hooks.Timeouts{Budget: 60 * time.Millisecond, Policy: hooks.AbortOnTimeout})
+
+	err := h.DoActionE("test.action")
+	var timeoutErr *hooks.TimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Namespace != "my_callback_b" {
+		t.Errorf("Expected %v to be a timeout of my_callback_b", err)
+	}
+}
+
+// Repeatedly failing callbacks are skipped until their circuit closes again
+func TestCircuitBreaker(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var changes []string
+	h.AddAction("BreakerStateChanged", "my_callback", func(i ...interface{}) interface{} {
+		changes = append(changes, fmt.Sprintf("%s %s %v->%v", i[0], i[1], i[2], i[3]))
+		return nil
+	}, 10)
+
+	calls := 0
+	failing := true
+	h.AddFilter("test.filter", "my_callback_filter_a", filterA, 10)
+	h.AddFilterE("test.filter", "my_callback_failing", func(ctx context.Context, i ...interface{}) (interface{}, error) {
+		calls++
+		if failing {
+			return nil, errors.New("failing")
+		}
+		return i[0].(string) + "f", nil
+	}, 11)
+
+	h.SetFilterErrorPolicy("test.filter", hooks.ContinueOnError)
+	h.SetFilterBreaker("", hooks.BreakerOptions{Failures: 2, Window: time.Minute, Cooldown: 30 * time.Millisecond})
+
+	for i := 0; i < 4; i++ {
+		h.ApplyFilters("test.filter", "")
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected %d to be equal to %d", calls, 2)
+	}
+
+	expectedInfo := []hooks.BreakerInfo{{Hook: "test.filter", Namespace: "my_callback_failing", State: hooks.BreakerOpen}}
+	if v := h.FilterBreakers(); !reflect.DeepEqual(v, expectedInfo) {
+		t.Errorf("Expected %v to be equal to %v", v, expectedInfo)
+	}
+
+	// The trial call after the cooldown fails, so the circuit opens again.
+	time.Sleep(40 * time.Millisecond)
+	h.ApplyFilters("test.filter", "")
+
+	time.Sleep(40 * time.Millisecond)
+	failing = false
+	if v, err := h.ApplyFiltersE("test.filter", ""); v != "af" || err != nil {
+		t.Errorf("Expected %v, %v to be equal to %v, nil", v, err, "af")
+	}
+
+	if v := h.FilterBreakers(); len(v) != 0 {
+		t.Errorf("Expected %v to be empty", v)
+	}
+
+	expected := []string{
+		"test.filter my_callback_failing closed->open",
+		"test.filter my_callback_failing open->half-open",
+		"test.filter my_callback_failing half-open->open",
+		"test.filter my_callback_failing open->half-open",
+		"test.filter my_callback_failing half-open->closed",
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %v to be equal to %v", changes, expected)
+	}
+
+	if calls != 4 {
+		t.Errorf("Expected %d to be equal to %d", calls, 4)
+	}
+}
+
+// A trial call panicking through the hook opens the circuit again
+func TestCircuitBreakerPanickingTrial(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	calls := 0
+	failing := true
+	h.AddFilterE("test.filter", "my_callback_failing", func(ctx context.Context, i ...interface{}) (interface{}, error) {
+		calls++
+		if failing {
+			panic("boom")
+		}
+		return i[0].(string) + "f", nil
+	}, 10)
+
+	h.SetFilterBreaker("", hooks.BreakerOptions{Failures: 1, Window: time.Minute, Cooldown: 30 * time.Millisecond})
+
+	applyFilters := func() (v interface{}, r interface{}) {
+		defer func() {
+			r = recover()
+		}()
+		return h.ApplyFilters("test.filter", ""), nil
+	}
+
+	// The panic opens the circuit, and so does the panic of the trial call.
+	for i := 0; i < 2; i++ {
+		if _, r := applyFilters(); r != "boom" {
+			t.Errorf("Expected %v to be equal to %v", r, "boom")
+		}
+
+		expectedInfo := []hooks.BreakerInfo{{Hook: "test.filter", Namespace: "my_callback_failing", State: hooks.BreakerOpen}}
+		if v := h.FilterBreakers(); !reflect.DeepEqual(v, expectedInfo) {
+			t.Errorf("Expected %v to be equal to %v", v, expectedInfo)
+		}
+
+		time.Sleep(40 * time.Millisecond)
+	}
+
+	failing = false
+	if v, r := applyFilters(); v != "f" || r != nil {
+		t.Errorf("Expected %v, %v to be equal to %v, nil", v, r, "f")
+	}
+
+	if calls != 3 {
+		t.Errorf("Expected %d to be equal to %d", calls, 3)
+	}
+}
+
+// A Hook handle runs the action and the filter of its name
+func TestHookHandle(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	hook := h.Hook("test.filter")
+
+	if hook.Has(hooks.KindFilter) {
+		t.Errorf("Expected %v to be equal to %v", true, false)
+	}
+
+	if v := hook.Apply("test"); v != "test" {
+		t.Errorf("Expected %v to be equal to %v", v, "test")
+	}
+
+	if !hook.Has(hooks.KindFilter) || hook.Has(hooks.KindAction) {
+		t.Errorf("Expected %v, %v to be equal to %v, %v", hook.Has(hooks.KindFilter), hook.Has(hooks.KindAction), true, false)
+	}
+
+	// Callbacks added and removed after the handle is created are seen.
+	h.AddFilter("test.filter", "my_callback_filter_a", filterA, 10)
+	h.AddFilter("test.filter", "my_callback_filter_b", filterB, 11)
+
+	if v := hook.Apply("test"); v != "testab" {
+		t.Errorf("Expected %v to be equal to %v", v, "testab")
+	}
+
+	h.RemoveFilter("test.filter", "my_callback_filter_a")
+
+	if v := hook.Apply("test"); v != "testb" {
+		t.Errorf("Expected %v to be equal to %v", v, "testb")
+	}
+
+	if v := h.ApplyFilters("test.filter", "test"); v != "testb" {
+		t.Errorf("Expected %v to be equal to %v", v, "testb")
+	}
+
+	if hook.Did(hooks.KindFilter) != 4 || h.DidFilter("test.filter") != 4 {
+		t.Errorf("Expected %v to be equal to %v", hook.Did(hooks.KindFilter), 4)
+	}
+
+	h.AddAction("test.filter", "my_callback", actionA, 10)
+	hook.Do()
+
+	if actionValue != "a" || hook.Did(hooks.KindAction) != 1 {
+		t.Errorf("Expected %v to be equal to %v", actionValue, "a")
+	}
+
+	var observed []interface{}
+	h.AddAction("all", "my_callback", func(i ...interface{}) interface{} {
+		observed = append(observed, i[0], i[1], len(i[2].([]interface{})))
+		return nil
+	}, 10)
+
+	if v, err := hook.ApplyContext(context.Background(), "test", 1); v != "testb" || err != nil {
+		t.Errorf("Expected %v, %v to be equal to %v, nil", v, err, "testb")
+	}
+
+	expected := []interface{}{"test.filter", hooks.KindFilter, 2}
+	if !reflect.DeepEqual(observed, expected) {
+		t.Errorf("Expected %v to be equal to %v", observed, expected)
+	}
+}
+
+// A Hook handle does not allocate when its hook has no callbacks
+func TestHookHandleAllocations(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	hook := h.Hook("test.filter")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		hook.Apply("test", 1)
+		hook.Do("test")
+	})
+	if allocs != 0 {
+		t.Errorf("Expected %v to be equal to %v", allocs, 0)
+	}
+
+	if hook.Did(hooks.KindFilter) != 101 {
+		t.Errorf("Expected %v to be equal to %v", hook.Did(hooks.KindFilter), 101)
+	}
+}
+
+// countingDispatcher is a decorator counting the actions run through it
+type countingDispatcher struct {
+	hooks.Interface
+	runs map[string]int
+}
+
+func (d countingDispatcher) DoAction(hookName string, args ...interface{}) interface{} {
+	d.runs[hookName]++
+	return d.Interface.DoAction(hookName, args...)
+}
+
+// WithOwner wraps the Registry of a plugin, as a decorator should.
+func (d countingDispatcher) WithOwner(owner string) hooks.Registry {
+	return ownedRegistry{d.Interface.WithOwner(owner)}
+}
+
+// ownedRegistry is a decorator of the Registry of a plugin
+type ownedRegistry struct {
+	hooks.Registry
+}
+
+func (d countingDispatcher) DoActionContext(ctx context.Context, hookName string, args ...interface{}) error {
+	d.runs[hookName]++
+	return d.Interface.DoActionContext(ctx, hookName, args...)
+}
+
+// fakeDispatcher is a Dispatcher whose filters return a fixed value
+type fakeDispatcher struct {
+	hooks.Dispatcher
+	value interface{}
+}
+
+func (d fakeDispatcher) ApplyFilters(hookName string, args ...interface{}) interface{} {
+	return d.value
+}
+
+func (d fakeDispatcher) ApplyFiltersContext(ctx context.Context, hookName string, args ...interface{}) (interface{}, error) {
+	return d.value, nil
+}
+
+func applyTitle(d hooks.Dispatcher) interface{} {
+	return d.ApplyFilters("the_title", "title")
+}
+
+// An Engine can be decorated and shares its hooks with its Core
+func TestEngine(t *testing.T) {
+	engine := hooks.New()
+	engine.AddAction("test.action", "my_callback", actionA, 10)
+	engine.AddFilter("the_title", "my_callback", filterA, 10)
+
+	var i hooks.Interface = countingDispatcher{engine, map[string]int{}}
+	i.DoAction("test.action")
+	i.DoAction("test.action")
+
+	if actionValue != "aa" || i.(countingDispatcher).runs["test.action"] != 2 || i.DidAction("test.action") != 2 {
+		t.Errorf("Expected %v to be equal to %v", actionValue, "aa")
+	}
+	actionValue = ""
+
+	if v := applyTitle(engine); v != "titlea" {
+		t.Errorf("Expected %v to be equal to %v", v, "titlea")
+	}
+
+	if v := applyTitle(fakeDispatcher{value: "fake"}); v != "fake" {
+		t.Errorf("Expected %v to be equal to %v", v, "fake")
+	}
+
+	// The Core of an Engine works on the same hooks.
+	core := engine.Core()
+	core.AddFilter("the_title", "my_callback_b", filterB, 11)
+
+	if v := engine.ApplyFilters("the_title", "title"); v != "titleab" {
+		t.Errorf("Expected %v to be equal to %v", v, "titleab")
+	}
+
+	// Replacing a function of a Core does not affect its Engine.
+	wrapped := core.Engine()
+	core.ApplyFilters = func(hookName string, args ...interface{}) interface{} {
+		return nil
+	}
+
+	if v := wrapped.ApplyFilters("the_title", "title"); v != "titleab" {
+		t.Errorf("Expected %v to be equal to %v", v, "titleab")
+	}
+}
+
+// The APIs built on hooks take a decorator or a fake as well as an Engine
+func TestEngineDecorated(t *testing.T) {
+	engine := hooks.New()
+	counting := countingDispatcher{engine, map[string]int{}}
+
+	// The plugins run hooks through the decorator, and their callbacks are
+	// recorded as theirs.
+	pm := hooks.NewPluginManager(counting)
+	err := pm.Activate(&testPlugin{name: "my-plugin", version: "1.0.0", activate: func(core *hooks.Core) error {
+		core.AddAction("test.action", "my_callback", actionA, 10)
+		core.DoAction("test.action")
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if handlers := engine.ActionHandlers("test.action"); len(handlers) != 1 || handlers[0].Owner != "my-plugin" {
+		t.Errorf("Expected %v to be equal to %v", handlers, "my-plugin")
+	}
+
+	if counting.runs["test.action"] != 1 || counting.runs["plugin_activated"] != 1 {
+		t.Errorf("Expected %v to be equal to %v", counting.runs, map[string]int{"test.action": 1, "plugin_activated": 1})
+	}
+
+	if err := pm.Deactivate("my-plugin"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(engine.ActionHandlers("test.action")) != 0 {
+		t.Errorf("Expected %v to be equal to %v", engine.ActionHandlers("test.action"), []hooks.HandlerInfo{})
+	}
+	actionValue = ""
+
+	// A typed filter works on a fake.
+	title := hooks.NewFilter[string](struct {
+		hooks.Registry
+		hooks.Dispatcher
+	}{engine, fakeDispatcher{engine, "fake"}}, "the_title")
+
+	if v := title.Apply("title"); v != "fake" {
+		t.Errorf("Expected %v to be equal to %v", v, "fake")
+	}
+
+	// A pool runs its actions through the decorator.
+	pool := hooks.NewAsyncPool(counting, hooks.AsyncOptions{Workers: 1})
+	pool.DoActionAsync("test.async")
+	pool.Close()
+
+	if counting.runs["test.async"] != 1 {
+		t.Errorf("Expected %v to be equal to %v", counting.runs["test.async"], 1)
+	}
+}
+
+// Hooks, their callbacks and the running hooks can be listed
+func TestIntrospection(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var stack []hooks.HookInfo
+	var current hooks.HookInfo
+	h.AddFilter("test.filter", "my_callback_filter_a", filterA, 10)
+	h.AddFilter("test.filter", "my_callback_stack", func(i ...interface{}) interface{} {
+		stack = h.Stack()
+		current, _ = h.CurrentAction()
+		return i[0]
+	}, 11)
+	h.AddAction("test.action", "my_callback", func(i ...interface{}) interface{} {
+		h.ApplyFilters("test.filter", "test")
+		return nil
+	}, 10)
+
+	h.ApplyFilters("test.filter", "test")
+	h.DoAction("test.action")
+
+	expected := []hooks.HookInfo{
+		{Name: "test.action", Kind: hooks.KindAction, CurrentIndex: 0, Namespace: "my_callback", Priority: 10},
+		{Name: "test.filter", Kind: hooks.KindFilter, CurrentIndex: 1, Depth: 1, Parent: "test.action", Namespace: "my_callback_stack", Priority: 11},
+	}
+	if !reflect.DeepEqual(stack, expected) {
+		t.Errorf("Expected %v to be equal to %v", stack, expected)
+	}
+
+	if current.Name != "test.action" {
+		t.Errorf("Expected %v to be equal to %v", current.Name, "test.action")
+	}
+
+	if stack := h.Stack(); len(stack) != 0 {
+		t.Errorf("Expected %v to be equal to %v", stack, []hooks.HookInfo{})
+	}
+
+	summaries := h.ListHooks()
+	expectedSummaries := []hooks.HookSummary{
+		{Name: "HookAdded", Kind: hooks.KindAction, Runs: 3},
+		{Name: "test.action", Kind: hooks.KindAction, Handlers: 1, Runs: 1},
+		{Name: "test.filter", Kind: hooks.KindFilter, Handlers: 2, Runs: 2},
+	}
+	if !reflect.DeepEqual(summaries, expectedSummaries) {
+		t.Errorf("Expected %v to be equal to %v", summaries, expectedSummaries)
+	}
+
+	handlers := h.FilterHandlers("test.filter")
+	if len(handlers) != 2 || handlers[1].Namespace != "my_callback_stack" || handlers[1].Priority != 11 {
+		t.Errorf("Expected %v to be equal to %v", handlers, "my_callback_filter_a and my_callback_stack")
+	}
+
+	for _, handler := range handlers {
+		if filepath.Base(handler.Source[:strings.LastIndex(handler.Source, ":")]) != "hooks_test.go" {
+			t.Errorf("Expected %v to be in %v", handler.Source, "hooks_test.go")
+		}
+	}
+
+	if handlers := h.ActionHandlers("test.filter"); len(handlers) != 0 {
+		t.Errorf("Expected %v to be equal to %v", handlers, []hooks.HandlerInfo{})
+	}
+}
+
+// DoingAction finds nested actions, and HookInfo tells how they are nested
+func TestNestedHooks(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var doing []bool
+	var infos []hooks.HookInfo
+	h.AddAction("save_post", "my_callback_save", func(i ...interface{}) interface{} {
+		h.DoAction("clean_cache")
+		return nil
+	}, 10)
+	h.AddAction("clean_cache", "my_callback_clean", func(i ...interface{}) interface{} {
+		h.ApplyFilters("test.filter", "test")
+		return nil
+	}, 12)
+	h.AddFilter("test.filter", "my_callback_filter", func(i ...interface{}) interface{} {
+		doing = append(doing, h.DoingAction("save_post"), h.DoingAction("clean_cache"), h.DoingFilter("test.filter"), h.DoingFilter("save_post"))
+
+		action, _ := h.CurrentAction()
+		filter, _ := h.CurrentFilter()
+		infos = append(infos, action, filter)
+		return i[0]
+	}, 11)
+
+	h.DoAction("save_post")
+
+	expectedDoing := []bool{true, true, true, false}
+	if !reflect.DeepEqual(doing, expectedDoing) {
+		t.Errorf("Expected %v to be equal to %v", doing, expectedDoing)
+	}
+
+	expected := []hooks.HookInfo{
+		{Name: "clean_cache", Kind: hooks.KindAction, Depth: 1, Parent: "save_post", Namespace: "my_callback_clean", Priority: 12},
+		{Name: "test.filter", Kind: hooks.KindFilter, Depth: 2, Parent: "clean_cache", Namespace: "my_callback_filter", Priority: 11},
+	}
+	if !reflect.DeepEqual(infos, expected) {
+		t.Errorf("Expected %v to be equal to %v", infos, expected)
+	}
+
+	if h.DoingAction("save_post") || h.DoingAction("") {
+		t.Errorf("Expected %v to be equal to %v", true, false)
+	}
+}
+
+func benchmarkCore() hooks.Core {
+	core := hooks.CreateHooks()
+	core.AddFilter("test.filter", "my_callback_filter_a", filterA, 10)
+	core.AddFilter("test.filter", "my_callback_filter_b", filterB, 11)
+	core.AddFilter("test.filter", "my_callback_filter_c", filterC, 12)
+	core.AddAction("test.action", "my_callback_action", func(i ...interface{}) interface{} {
+		return nil
+	}, 10)
+	return core
+}
+
+func BenchmarkApplyFilters(b *testing.B) {
+	core := benchmarkCore()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		core.ApplyFilters("test.filter", "test")
+	}
+}
+
+func BenchmarkApplyFiltersNoCallbacks(b *testing.B) {
+	core := benchmarkCore()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		core.ApplyFilters("test.empty", "test")
+	}
+}
+
+func BenchmarkDoAction(b *testing.B) {
+	core := benchmarkCore()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		core.DoAction("test.action", 1)
+	}
+}
+
+func BenchmarkApplyFiltersConcurrent(b *testing.B) {
+	core := benchmarkCore()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			core.ApplyFilters("test.filter", "test")
+		}
+	})
+}
+
+func BenchmarkHookApply(b *testing.B) {
+	core := benchmarkCore()
+	hook := core.Hook("test.filter")
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		hook.Apply("test")
+	}
+}
+
+func BenchmarkHookApplyNoCallbacks(b *testing.B) {
+	core := benchmarkCore()
+	hook := core.Hook("test.empty")
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		hook.Apply("test")
+	}
+}
+
+// baselineRun runs a hook the way the first version of the package did: a
+// single registry, unsafe for concurrent use, whose running hooks were kept
+// in Hooks.Current. The Baseline benchmarks run it over the handlers of
+// benchmarkCore, to compare the dispatch with where it started.
+func baselineRun(registry *hooks.Hooks, hookName string, returnFirstArg bool, args ...interface{}) interface{} {
+	if entry, ok := registry.Hooks[hookName]; ok {
+		entry.Runs++
+		registry.Hooks[hookName] = entry
+	} else {
+		registry.Hooks[hookName] = hooks.Handlers{
+			Handlers: []hooks.Handler{},
+			Runs:     1,
+		}
+	}
+
+	if len(registry.Hooks[hookName].Handlers) == 0 {
+		if returnFirstArg {
+			return args[0]
+		}
+		return nil
+	}
+
+	hookInfo := hooks.HookInfo{
+		Name:         hookName,
+		CurrentIndex: 0,
+	}
+
+	registry.Current = append(registry.Current, &hookInfo)
+
+	var result interface{}
+
+	for hookInfo.CurrentIndex < len(registry.Hooks[hookName].Handlers) {
+		handler := registry.Hooks[hookName].Handlers[hookInfo.CurrentIndex]
+		result = handler.Callback(args...)
+		if returnFirstArg {
+			args[0] = result
+		}
+		hookInfo.CurrentIndex++
+	}
+
+	registry.Current = registry.Current[:len(registry.Current)-1]
+
+	if returnFirstArg {
+		return args[0]
+	}
+
+	return nil
+}
+
+// baselineRegistries returns registries holding the actions and the filters
+// of benchmarkCore.
+func baselineRegistries() (actions *hooks.Hooks, filters *hooks.Hooks) {
+	core := benchmarkCore()
+	actions = &hooks.Hooks{Hooks: map[string]hooks.Handlers{
+		"test.action": core.Actions.Hooks["test.action"],
+	}}
+	filters = &hooks.Hooks{Hooks: map[string]hooks.Handlers{
+		"test.filter": core.Filters.Hooks["test.filter"],
+	}}
+
+	return actions, filters
+}
+
+func BenchmarkBaselineApplyFilters(b *testing.B) {
+	_, filters := baselineRegistries()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		baselineRun(filters, "test.filter", true, "test")
+	}
+}
+
+func BenchmarkBaselineApplyFiltersNoCallbacks(b *testing.B) {
+	_, filters := baselineRegistries()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		baselineRun(filters, "test.empty", true, "test")
+	}
+}
+
+func BenchmarkBaselineDoAction(b *testing.B) {
+	actions, _ := baselineRegistries()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		baselineRun(actions, "test.action", false, 1)
+	}
+}
@@ -0,0 +1,132 @@
+package hooks
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// HookSummary describes a hook, as listed by Core.ListHooks.
+type HookSummary struct {
+	Name string
+	Kind Kind
+	// Handlers is the number of callbacks registered to the hook.
+	Handlers int
+	// Runs is the number of times the hook has been run.
+	Runs int
+}
+
+// HandlerInfo describes a callback registered to a hook, as listed by
+// Core.ActionHandlers and Core.FilterHandlers.
+type HandlerInfo struct {
+	Namespace string
+	Priority  int
+	// Pattern is the hook pattern the callback was added with, if any.
+	Pattern string
+	// Owner is the name of the Plugin that added the callback, if any.
+	Owner string
+	// Source is where the callback was added, as "file:line".
+	Source string
+	// Disabled is set when the callback was disabled through its
+	// Subscription.
+	Disabled bool
+	// Suspended is set when the namespace of the callback is suspended.
+	Suspended bool
+}
+
+// ListHooks returns every action and filter, sorted by name, actions first.
+func (e *Engine) ListHooks() []HookSummary {
+	summaries := []HookSummary{}
+
+	for _, hooks := range []*Hooks{e.actions, e.filters} {
+		hooks.mu.RLock()
+		for hookName, entry := range hooks.Hooks {
+			summary := HookSummary{
+				Name:     hookName,
+				Kind:     hooks.kind,
+				Handlers: len(entry.Handlers),
+			}
+			if state := hooks.dispatch.states[hookName]; state != nil {
+				summary.Runs = int(state.runs.Load())
+			}
+			summaries = append(summaries, summary)
+		}
+		hooks.mu.RUnlock()
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].Name < summaries[j].Name
+	})
+
+	return summaries
+}
+
+// ActionHandlers returns the callbacks registered to an action, in the order
+// they run.
+func (e *Engine) ActionHandlers(hookName string) []HandlerInfo {
+	return listHandlers(e.actions, hookName)
+}
+
+// FilterHandlers returns the callbacks registered to a filter, in the order
+// they run.
+func (e *Engine) FilterHandlers(hookName string) []HandlerInfo {
+	return listHandlers(e.filters, hookName)
+}
+
+// listHandlers returns the callbacks registered to a hook, in the order they
+// run.
+func listHandlers(hooks *Hooks, hookName string) []HandlerInfo {
+	hooks.mu.RLock()
+	defer hooks.mu.RUnlock()
+
+	infos := []HandlerInfo{}
+	for _, handler := range hooks.Hooks[hookName].Handlers {
+		infos = append(infos, HandlerInfo{
+			Namespace: handler.Namespace,
+			Priority:  handler.Priority,
+			Pattern:   handler.Pattern,
+			Owner:     handler.Owner,
+			Source:    handler.source,
+			Disabled:  handler.disabled,
+			Suspended: handler.suspended,
+		})
+	}
+
+	return infos
+}
+
+// Stack returns the actions and filters running in the calling goroutine,
+// outermost first.
+func (e *Engine) Stack() []HookInfo {
+	stack := e.actions.stack()
+
+	infos := make([]HookInfo, 0, len(stack))
+	for i := range stack {
+		infos = append(infos, stackInfo(stack, i))
+	}
+
+	return infos
+}
+
+// packagePrefix prefixes the names of the functions of the package.
+var packagePrefix = reflect.TypeOf(Core{}).PkgPath() + "."
+
+// callerSource returns the location, as "file:line", of the first caller
+// outside the package.
+func callerSource() string {
+	var pcs [16]uintptr
+	n := runtime.Callers(2, pcs[:])
+
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, packagePrefix) {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package hooks
+
+// RemoveNamespace removes the callbacks of every action and filter whose
+// namespace matches a pattern, such as "vendor/plugin/*", triggering the
+// HookRemoved action for each of them. It returns the number of callbacks
+// removed.
+func (e *Engine) RemoveNamespace(pattern string) int {
+	return e.removeHandlers(func(handler Handler) bool {
+		return matchNamespace(pattern, handler.Namespace)
+	})
+}
+
+// removeHandlers removes the handlers of every action and filter for which
+// match returns true, triggering the HookRemoved action for each of them, and
+// returns how many were removed.
+func (e *Engine) removeHandlers(match func(Handler) bool) int {
+	type removal struct {
+		hookName  string
+		namespace string
+	}
+
+	var removed []removal
+
+	for _, hooks := range []*Hooks{e.actions, e.filters} {
+		hooks.mu.Lock()
+
+		for _, hookName := range hooks.names() {
+			handlers := hooks.Hooks[hookName].Handlers
+			for i := len(handlers) - 1; i >= 0; i-- {
+				if match(handlers[i]) {
+					handler := hooks.removeHandlerAt(hookName, i)
+					removed = append(removed, removal{hookName, handler.Namespace})
+				}
+			}
+		}
+
+		// Hooks created from now on must not get the callbacks back.
+		hooks.removePatterns(match)
+
+		hooks.mu.Unlock()
+	}
+
+	for _, r := range removed {
+		if r.hookName != "HookRemoved" {
+			e.DoAction("HookRemoved", r.hookName, r.namespace)
+		}
+	}
+
+	return len(removed)
+}
+
+// SuspendNamespace suspends the callbacks of every action and filter whose
+// namespace matches a pattern. Suspended callbacks stay registered but are
+// skipped when their hook runs. It returns the number of callbacks suspended.
+func (e *Engine) SuspendNamespace(pattern string) int {
+	return e.suspendNamespace(pattern, true)
+}
+
+// ResumeNamespace resumes the callbacks of every action and filter whose
+// namespace matches a pattern. It returns the number of callbacks resumed.
+func (e *Engine) ResumeNamespace(pattern string) int {
+	return e.suspendNamespace(pattern, false)
+}
+
+// suspendNamespace suspends or resumes the callbacks of every action and
+// filter whose namespace matches a pattern. It returns the number of
+// callbacks whose state changed.
+func (e *Engine) suspendNamespace(pattern string, suspend bool) int {
+	changed := 0
+
+	for _, hooks := range []*Hooks{e.actions, e.filters} {
+		hooks.mu.Lock()
+
+		for hookName, entry := range hooks.Hooks {
+			modified := false
+			for i := range entry.Handlers {
+				handler := &entry.Handlers[i]
+				if handler.suspended != suspend && matchNamespace(pattern, handler.Namespace) {
+					handler.suspended = suspend
+					modified = true
+					changed++
+				}
+			}
+			if modified {
+				hooks.publish(hookName)
+			}
+		}
+
+		// So that hooks created from now on get the callbacks in the
+		// same state.
+		for i := range hooks.patterns {
+			if matchNamespace(pattern, hooks.patterns[i].Namespace) {
+				hooks.patterns[i].suspended = suspend
+			}
+		}
+
+		hooks.mu.Unlock()
+	}
+
+	return changed
+}
@@ -0,0 +1,110 @@
+package hooks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrOrderCycle is returned when the ordering constraints of the callbacks of
+// a hook cannot all be satisfied.
+var ErrOrderCycle = errors.New("ordering constraints form a cycle")
+
+// Order constrains when a callback runs relative to the callbacks of other
+// namespaces of the same hook. Each entry is a namespace pattern, either an
+// exact namespace, a prefix such as "vendor/cache/*" matching every namespace
+// below it, or any other path.Match pattern. Constraints always win over
+// priorities, which only decide between callbacks that are not constrained
+// relative to each other. Patterns matching no callback are ignored.
+type Order struct {
+	// After lists the namespaces whose callbacks must run before this one.
+	After []string
+	// Before lists the namespaces whose callbacks must run after this one.
+	Before []string
+}
+
+func (o Order) isZero() bool {
+	return len(o.After) == 0 && len(o.Before) == 0
+}
+
+// sortHandlers orders the handlers of a hook so that their Order constraints
+// are satisfied, breaking ties by priority then by the order in which they
+// were added. The list is left untouched if the constraints form a cycle. It
+// must be called with hooks.mu held.
+func (hooks *Hooks) sortHandlers(hookName string) error {
+	handlers := hooks.Hooks[hookName].Handlers
+
+	constrained := false
+	for _, handler := range handlers {
+		if !handler.Order.isZero() {
+			constrained = true
+			break
+		}
+	}
+
+	if !constrained {
+		return nil
+	}
+
+	// edges[i] lists the handlers that must run after handler i.
+	edges := make([][]int, len(handlers))
+	inDegree := make([]int, len(handlers))
+
+	for i, handler := range handlers {
+		for j, other := range handlers {
+			if i == j {
+				continue
+			}
+
+			if matchAnyNamespace(handler.Order.Before, other.Namespace) || matchAnyNamespace(other.Order.After, handler.Namespace) {
+				edges[i] = append(edges[i], j)
+				inDegree[j]++
+			}
+		}
+	}
+
+	sorted := make([]Handler, 0, len(handlers))
+	done := make([]bool, len(handlers))
+
+	for len(sorted) < len(handlers) {
+		next := -1
+		for i, handler := range handlers {
+			if done[i] || inDegree[i] > 0 {
+				continue
+			}
+
+			if next < 0 || handler.Priority < handlers[next].Priority ||
+				handler.Priority == handlers[next].Priority && handler.id < handlers[next].id {
+				next = i
+			}
+		}
+
+		if next < 0 {
+			var cycle []string
+			for i, handler := range handlers {
+				if !done[i] {
+					cycle = append(cycle, handler.Namespace)
+				}
+			}
+			return fmt.Errorf("hook %s: %w between %s", hookName, ErrOrderCycle, strings.Join(cycle, ", "))
+		}
+
+		done[next] = true
+		sorted = append(sorted, handlers[next])
+		for _, j := range edges[next] {
+			inDegree[j]--
+		}
+	}
+
+	hooks.reorder(hookName, sorted)
+
+	return nil
+}
+
+// reorder replaces the handlers of a hook by the same handlers in another
+// order.
+func (hooks *Hooks) reorder(hookName string, sorted []Handler) {
+	entry := hooks.Hooks[hookName]
+	entry.Handlers = sorted
+	hooks.Hooks[hookName] = entry
+}
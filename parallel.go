@@ -0,0 +1,212 @@
+package hooks
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// SetActionParallel makes an action run its callbacks concurrently, at most
+// concurrency at a time. A negative concurrency means no limit, and 0
+// restores the default sequential dispatch.
+func (e *Engine) SetActionParallel(hookName string, concurrency int) {
+	e.actions.configure(func(config *hookConfig) {
+		if concurrency == 0 {
+			delete(config.parallel, hookName)
+			return
+		}
+
+		config.parallel[hookName] = concurrency
+	})
+}
+
+// DoActionCollect executes all callbacks registered to an action and returns
+// the value returned by each callback that ran, in priority order.
+func (e *Engine) DoActionCollect(ctx context.Context, hookName string, args ...interface{}) ([]interface{}, error) {
+	results := []interface{}{}
+	_, err := e.runHook(ctx, e.actions, hookName, false, args, &results)
+	return results, err
+}
+
+// runParallel is the part of runHook running the callbacks of a parallel
+// action, given the snapshot of its handlers and its settings when it started.
+//
+// The callbacks registered when the action starts all run, each in its own
+// goroutine which sees the hooks running in the caller.
+// An error under StopOnError, a recovered panic under AbortOnPanic, a
+// callback stopping the action or the end of ctx prevent the callbacks that
+// have not started yet from running. Under PropagatePanic, panics are
+// recovered in the goroutines without triggering HookFailed, and the first
+// one is raised again in the caller as a *PanicError, holding the stack of
+// the callback, once every callback has returned.
+//
+// Each callback is limited by its timeout, and the action by its budget, as
+// the callbacks which have not started yet when the budget is exhausted do
+// not run; the timeouts are handled according to their TimeoutPolicy. The
+// callbacks of a namespace whose circuit breaker is open do not run either.
+func (e *Engine) runParallel(ctx context.Context, hooks *Hooks, hookName string, snapshot *[]Handler, config *hookConfig, concurrency int, args []interface{}, results *[]interface{}) error {
+	policy := config.errorPolicy(hookName)
+	panicPolicy := config.panicPolicy(hookName)
+	timeouts := config.timeoutsOf(hookName)
+
+	var handlers []Handler
+	var indexes []int
+	var limits []time.Duration
+	for i, handler := range *snapshot {
+		if handler.active() {
+			handlers = append(handlers, handler)
+			indexes = append(indexes, i)
+			limits = append(limits, config.callbackTimeout(handler.Namespace, timeouts))
+		}
+	}
+
+	parents := share()
+
+	if concurrency < 0 || concurrency > len(handlers) {
+		concurrency = len(handlers)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var deadline time.Time
+	if timeouts.Budget > 0 {
+		deadline = time.Now().Add(timeouts.Budget)
+	}
+	exhausted := false
+
+	outcomes := make([]outcome, len(handlers))
+	started := make([]bool, len(handlers))
+	semaphore := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	var errs []error
+
+	for i := range handlers {
+		select {
+		case semaphore <- struct{}{}:
+		case <-runCtx.Done():
+		}
+
+		// Checked again in case the semaphore was acquired after runCtx ended.
+		if runCtx.Err() != nil {
+			break
+		}
+
+		timeout := limits[i]
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				exhausted = true
+				break
+			}
+
+			if timeout <= 0 || remaining < timeout {
+				timeout = remaining
+			}
+		}
+
+		allowed, change := hooks.breakers.allow(hookName, handlers[i].Namespace)
+		change.report(e)
+
+		if !allowed {
+			<-semaphore
+			continue
+		}
+
+		started[i] = true
+		wg.Add(1)
+
+		go func(i int, timeout time.Duration) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			slot := hooks.dispatch.group.acquire(hooks, hookName)
+			defer slot.release()
+
+			slot.parents.Store(parents)
+			slot.at(snapshot, indexes[i])
+
+			c := &invocation{ctx: runCtx, engine: e, hooks: hooks, hookName: hookName, handler: handlers[i], recoverPanics: panicPolicy != PropagatePanic, args: args, timeout: timeout}
+			o := invokeParallel(slot, c)
+			outcomes[i] = o
+
+			hooks.breakers.record(hookName, handlers[i].Namespace, o.failed()).report(e)
+
+			if o.stop || slot.run.stopped.Load() ||
+				o.panicErr != nil && panicPolicy != ContinueOnPanic ||
+				o.timedOut && timeouts.Policy == AbortOnTimeout ||
+				!o.timedOut && o.err != nil && policy == StopOnError {
+				cancel()
+			}
+		}(i, timeout)
+	}
+
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if exhausted {
+		err := e.budgetExhausted(hookName, timeouts.Budget)
+		if timeouts.Policy == AbortOnTimeout {
+			errs = append(errs, err)
+		}
+	}
+
+	for i, o := range outcomes {
+		if !started[i] {
+			continue
+		}
+
+		if o.panicErr != nil {
+			if panicPolicy == PropagatePanic {
+				panic(o.panicErr)
+			}
+			errs = append(errs, o.panicErr)
+			continue
+		}
+
+		if o.timedOut {
+			if timeouts.Policy == AbortOnTimeout {
+				errs = append(errs, o.err)
+			}
+			continue
+		}
+
+		if results != nil {
+			*results = append(*results, o.result)
+		}
+
+		if o.err != nil && policy != IgnoreErrors {
+			errs = append(errs, o.err)
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+// invokeParallel calls the callback of a parallel action below the markers of
+// slot. A panic the invocation does not recover from is recovered here, since
+// it cannot unwind through the caller of the action from another goroutine,
+// without triggering HookFailed.
+func invokeParallel(slot *slot, c *invocation) (o outcome) {
+	if !c.recoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				o = outcome{panicErr: &PanicError{
+					Hook:      c.hookName,
+					Namespace: c.handler.Namespace,
+					Priority:  c.handler.Priority,
+					Value:     r,
+					Stack:     debug.Stack(),
+				}}
+			}
+		}()
+	}
+
+	slot.mark(work{c: c})
+	return c.o
+}
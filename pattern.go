@@ -0,0 +1,116 @@
+package hooks
+
+// AddActionPattern adds a callback to every action whose name matches a
+// pattern.
+func (e *Engine) AddActionPattern(pattern string, namespace string, callback func(...interface{}) interface{}, priority int) {
+	e.addHookPattern(e.actions, pattern, namespace, callback, priority)
+}
+
+// AddFilterPattern adds a callback to every filter whose name matches a
+// pattern.
+func (e *Engine) AddFilterPattern(pattern string, namespace string, callback func(...interface{}) interface{}, priority int) {
+	e.addHookPattern(e.filters, pattern, namespace, callback, priority)
+}
+
+// addHookPattern adds a callback to every hook whose name matches a pattern
+// such as "save_post_*", "http.request.*" or "http.#", whether the hook
+// already exists or not. The callback takes its place among the other
+// callbacks of each hook according to its priority, and can get the name of
+// the hook being run from CurrentAction or CurrentFilter. A hook whose ordering constraints the callback would
+// contradict does not get it, and the HookFailed action is triggered for it
+// with an error wrapping ErrOrderCycle.
+func (e *Engine) addHookPattern(hooks *Hooks, pattern string, namespace string, callback func(...interface{}) interface{}, priority int) {
+	hooks.mu.Lock()
+
+	hooks.nextID++
+	handler := Handler{
+		Namespace: namespace,
+		Callback:  callback,
+		Priority:  priority,
+		Pattern:   pattern,
+		Owner:     e.owner,
+		id:        hooks.nextID,
+		source:    callerSource(),
+	}
+
+	hooks.patterns = append(hooks.patterns, handler)
+
+	var failed []string
+	var errs []error
+	for _, hookName := range hooks.names() {
+		if matchHookName(pattern, hookName) {
+			if err := hooks.insertHandler(hookName, handler); err != nil {
+				failed = append(failed, hookName)
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	hooks.mu.Unlock()
+
+	for i, hookName := range failed {
+		e.DoAction("HookFailed", hookName, namespace, priority, errs[i])
+	}
+
+	if pattern != "HookAdded" {
+		e.DoAction("HookAdded", pattern, namespace, callback, priority)
+	}
+}
+
+// RemoveActionPattern removes the callbacks added to the actions with a
+// pattern and a namespace.
+func (e *Engine) RemoveActionPattern(pattern string, namespace string) int {
+	return e.removeHookPattern(e.actions, pattern, namespace)
+}
+
+// RemoveFilterPattern removes the callbacks added to the filters with a
+// pattern and a namespace.
+func (e *Engine) RemoveFilterPattern(pattern string, namespace string) int {
+	return e.removeHookPattern(e.filters, pattern, namespace)
+}
+
+// removeHookPattern removes the callbacks added with a pattern and a
+// namespace from every hook. It returns the number of pattern callbacks
+// removed.
+func (e *Engine) removeHookPattern(hooks *Hooks, pattern string, namespace string) int {
+	hooks.mu.Lock()
+
+	removed := hooks.removePatterns(func(handler Handler) bool {
+		return handler.Pattern == pattern && handler.Namespace == namespace
+	})
+
+	hooks.mu.Unlock()
+
+	if removed > 0 && pattern != "HookRemoved" {
+		e.DoAction("HookRemoved", pattern, namespace)
+	}
+
+	return removed
+}
+
+// removePatterns removes the pattern handlers for which match returns true,
+// along with their copies in every hook, and returns how many were removed. It
+// must be called with hooks.mu held.
+func (hooks *Hooks) removePatterns(match func(Handler) bool) int {
+	kept := hooks.patterns[:0]
+	removed := 0
+
+	for _, handler := range hooks.patterns {
+		if !match(handler) {
+			kept = append(kept, handler)
+			continue
+		}
+
+		removed++
+
+		for hookName := range hooks.Hooks {
+			if i := hooks.handlerIndex(hookName, handler.id); i >= 0 {
+				hooks.removeHandlerAt(hookName, i)
+			}
+		}
+	}
+
+	hooks.patterns = kept
+
+	return removed
+}
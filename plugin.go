@@ -0,0 +1,421 @@
+package hooks
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrPluginActive is returned when activating a plugin whose name is
+	// already used by an active plugin.
+	ErrPluginActive = errors.New("plugin is already active")
+	// ErrPluginNotFound is returned when deactivating a plugin that is not
+	// active.
+	ErrPluginNotFound = errors.New("plugin is not active")
+	// ErrMissingDependency is returned when activating a plugin requiring a
+	// plugin which is neither active nor activated along with it.
+	ErrMissingDependency = errors.New("missing plugin dependency")
+	// ErrIncompatibleDependency is returned when activating a plugin
+	// requiring a version of a plugin other than the one available.
+	ErrIncompatibleDependency = errors.New("incompatible plugin dependency")
+	// ErrDependencyCycle is returned when activating plugins which require
+	// each other.
+	ErrDependencyCycle = errors.New("plugin dependency cycle")
+	// ErrPluginSymbol is returned by Load when a plugin file does not export
+	// a PluginSymbol of a supported type.
+	ErrPluginSymbol = errors.New("plugin symbol not found")
+	// ErrPluginVersion is returned by Load when a plugin file was built
+	// against other versions of the packages it shares with the program, or
+	// when the version of its plugin does not satisfy the constraint.
+	ErrPluginVersion = errors.New("plugin version mismatch")
+	// ErrPluginsUnsupported is returned by Load on platforms without
+	// support for Go plugins.
+	ErrPluginsUnsupported = errors.New("plugins are not supported on this platform")
+)
+
+// PluginSymbol is the symbol Load looks up in a plugin file. It must be a
+// variable of type Plugin or a function returning a Plugin:
+//
+//	var HooksPlugin hooks.Plugin = &MyPlugin{}
+const PluginSymbol = "HooksPlugin"
+
+// Plugin is a set of callbacks added and removed as a whole by a
+// PluginManager.
+type Plugin interface {
+	// Name identifies the plugin. It is recorded as the Owner of every
+	// callback the plugin adds.
+	Name() string
+	// Version is the version of the plugin, such as "1.2.0".
+	Version() string
+	// Activate adds the callbacks of the plugin to core.
+	Activate(core *Core) error
+	// Deactivate releases what the plugin holds besides its callbacks, which
+	// are removed by the PluginManager once it returns.
+	Deactivate(core *Core) error
+}
+
+// Dependency is a plugin required by another plugin.
+type Dependency struct {
+	// Name is the name of the required plugin.
+	Name string
+	// Constraint restricts the versions of the required plugin, such as
+	// ">=2.1" or "^2.1, !=2.3.0". An empty constraint allows any version.
+	Constraint string
+}
+
+// DependentPlugin is a Plugin requiring other plugins to be active. It is
+// activated after them, and deactivated along with any of them.
+type DependentPlugin interface {
+	Plugin
+	Dependencies() []Dependency
+}
+
+// PluginInfo describes an active plugin.
+type PluginInfo struct {
+	Name    string
+	Version string
+	// Path is the file the plugin was loaded from by Load, if any.
+	Path string
+}
+
+// PluginManager activates and deactivates the plugins of an Interface,
+// keeping track of the callbacks each of them added.
+//
+// Activating a plugin triggers the plugin_activated action, and deactivating
+// it the plugin_deactivated action, passing values for the name and the
+// version of the plugin.
+type PluginManager struct {
+	hooks Interface
+
+	mu     sync.Mutex
+	active []activePlugin
+	// pending holds the names of the plugins being activated. The lock is
+	// not held while a plugin activates, so that it may use the manager.
+	pending map[string]bool
+}
+
+type activePlugin struct {
+	plugin Plugin
+	// core is the Core given to the plugin, which records it as the Owner
+	// of the callbacks it adds.
+	core *Core
+	path string
+}
+
+// dependencies returns the plugins required by a plugin.
+func dependencies(p Plugin) []Dependency {
+	if dp, ok := p.(DependentPlugin); ok {
+		return dp.Dependencies()
+	}
+	return nil
+}
+
+// NewPluginManager returns a PluginManager adding the callbacks of its plugins
+// to hooks. The Core given to a plugin adds its callbacks through the
+// Registry returned by the WithOwner method of hooks, and runs hooks through
+// hooks itself.
+func NewPluginManager(hooks Interface) *PluginManager {
+	return &PluginManager{hooks: hooks, pending: map[string]bool{}}
+}
+
+// Activate activates plugins in order, except that a plugin is activated
+// after the plugins it depends on. No plugin is activated when the
+// dependencies of one of them are missing, incompatible or form a cycle.
+// Otherwise, Activate stops at the first plugin failing to activate, whose
+// callbacks are removed, and returns its error; the plugins before it stay
+// active.
+func (m *PluginManager) Activate(plugins ...Plugin) error {
+	return m.activateFrom("", plugins)
+}
+
+// activateFrom activates plugins loaded from the given path, if any.
+func (m *PluginManager) activateFrom(path string, plugins []Plugin) error {
+	plugins, err := m.resolve(plugins)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range plugins {
+		if err := m.activate(p, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve checks the dependencies of plugins against the active plugins and
+// the plugins themselves, and returns the plugins in the order they must be
+// activated.
+func (m *PluginManager) resolve(plugins []Plugin) ([]Plugin, error) {
+	m.mu.Lock()
+	versions := m.versions()
+	m.mu.Unlock()
+
+	batch := map[string]bool{}
+	for _, p := range plugins {
+		name := p.Name()
+		if _, ok := versions[name]; ok {
+			return nil, fmt.Errorf("activate plugin %q: %w", name, ErrPluginActive)
+		}
+		versions[name] = p.Version()
+		batch[name] = true
+	}
+
+	for _, p := range plugins {
+		if err := checkDependencies(p, versions); err != nil {
+			return nil, err
+		}
+	}
+
+	byName := make(map[string]Plugin, len(plugins))
+	for _, p := range plugins {
+		byName[p.Name()] = p
+	}
+
+	// Each plugin is placed after the plugins of the batch it depends on,
+	// which are placed first when they come later.
+	ordered := make([]Plugin, 0, len(plugins))
+	placed := map[string]bool{}
+	visiting := map[string]bool{}
+
+	var place func(p Plugin) error
+	place = func(p Plugin) error {
+		name := p.Name()
+		if placed[name] {
+			return nil
+		}
+		if visiting[name] {
+			return fmt.Errorf("activate plugin %q: %w", name, ErrDependencyCycle)
+		}
+		visiting[name] = true
+
+		for _, dep := range dependencies(p) {
+			if batch[dep.Name] {
+				if err := place(byName[dep.Name]); err != nil {
+					return err
+				}
+			}
+		}
+
+		visiting[name] = false
+		placed[name] = true
+		ordered = append(ordered, p)
+		return nil
+	}
+
+	for _, p := range plugins {
+		if err := place(p); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
+// checkDependencies checks that the plugins required by a plugin are among
+// versions, which maps plugin names to their versions, in a compatible
+// version.
+func checkDependencies(p Plugin, versions map[string]string) error {
+	for _, dep := range dependencies(p) {
+		v, ok := versions[dep.Name]
+		if !ok {
+			return fmt.Errorf("activate plugin %q: %w: %q", p.Name(), ErrMissingDependency, dep.Name)
+		}
+
+		parsed, err := parseVersion(v)
+		if err != nil {
+			return fmt.Errorf("activate plugin %q: dependency %q: %w", p.Name(), dep.Name, err)
+		}
+
+		ok, err = satisfies(parsed, dep.Constraint)
+		if err != nil {
+			return fmt.Errorf("activate plugin %q: dependency %q: %w", p.Name(), dep.Name, err)
+		}
+		if !ok {
+			return fmt.Errorf("activate plugin %q: %w: %q %s does not satisfy %q", p.Name(), ErrIncompatibleDependency, dep.Name, v, dep.Constraint)
+		}
+	}
+	return nil
+}
+
+func (m *PluginManager) activate(p Plugin, path string) error {
+	name := p.Name()
+
+	m.mu.Lock()
+	if m.index(name) >= 0 || m.pending[name] {
+		m.mu.Unlock()
+		return fmt.Errorf("activate plugin %q: %w", name, ErrPluginActive)
+	}
+	// The plugins it depends on may have been deactivated in the meantime.
+	if err := checkDependencies(p, m.versions()); err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	m.pending[name] = true
+	m.mu.Unlock()
+
+	scoped := &Core{}
+	bindCore(scoped, m.hooks.WithOwner(name), m.hooks)
+
+	err := p.Activate(scoped)
+	if err != nil {
+		m.removeHandlers(name)
+	}
+
+	m.mu.Lock()
+	delete(m.pending, name)
+	if err == nil {
+		m.active = append(m.active, activePlugin{plugin: p, core: scoped, path: path})
+	}
+	m.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("activate plugin %q: %w", name, err)
+	}
+
+	m.hooks.DoAction("plugin_activated", name, p.Version())
+
+	return nil
+}
+
+// Deactivate deactivates the plugin with the given name and removes its
+// callbacks, even if its Deactivate method returns an error, which is then
+// returned. The plugins depending on it, directly or not, are deactivated
+// first, in the reverse order of their activation.
+func (m *PluginManager) Deactivate(name string) error {
+	m.mu.Lock()
+	if m.index(name) < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("deactivate plugin %q: %w", name, ErrPluginNotFound)
+	}
+	names := m.dependents(name)
+	m.mu.Unlock()
+
+	var errs []error
+	for _, n := range names {
+		if err := m.deactivate(n); err != nil && !errors.Is(err, ErrPluginNotFound) {
+			errs = append(errs, err)
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+// dependents returns the name of a plugin preceded by the names of the
+// plugins depending on it, directly or not, in the reverse order of their
+// activation. It must be called with m.mu held.
+func (m *PluginManager) dependents(name string) []string {
+	affected := map[string]bool{name: true}
+
+	// A plugin is always activated after the plugins it depends on.
+	for _, a := range m.active {
+		for _, dep := range dependencies(a.plugin) {
+			if affected[dep.Name] {
+				affected[a.plugin.Name()] = true
+				break
+			}
+		}
+	}
+
+	var names []string
+	for i := len(m.active) - 1; i >= 0; i-- {
+		if n := m.active[i].plugin.Name(); affected[n] {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+func (m *PluginManager) deactivate(name string) error {
+	m.mu.Lock()
+	i := m.index(name)
+	if i < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("deactivate plugin %q: %w", name, ErrPluginNotFound)
+	}
+
+	a := m.active[i]
+	m.active = append(m.active[:i], m.active[i+1:]...)
+	m.mu.Unlock()
+
+	err := a.plugin.Deactivate(a.core)
+	m.removeHandlers(name)
+
+	m.hooks.DoAction("plugin_deactivated", name, a.plugin.Version())
+
+	if err != nil {
+		return fmt.Errorf("deactivate plugin %q: %w", name, err)
+	}
+	return nil
+}
+
+// DeactivateAll deactivates every active plugin in the reverse order of their
+// activation, and returns their errors joined together.
+func (m *PluginManager) DeactivateAll() error {
+	var errs []error
+
+	for _, info := range m.reversed() {
+		if err := m.Deactivate(info.Name); err != nil && !errors.Is(err, ErrPluginNotFound) {
+			errs = append(errs, err)
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+// Plugins returns the active plugins in the order of their activation.
+func (m *PluginManager) Plugins() []PluginInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	infos := make([]PluginInfo, len(m.active))
+	for i, a := range m.active {
+		infos[i] = PluginInfo{Name: a.plugin.Name(), Version: a.plugin.Version(), Path: a.path}
+	}
+	return infos
+}
+
+// IsActive reports whether the plugin with the given name is active.
+func (m *PluginManager) IsActive(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.index(name) >= 0
+}
+
+// reversed returns the active plugins in the reverse order of their
+// activation.
+func (m *PluginManager) reversed() []PluginInfo {
+	infos := m.Plugins()
+	for i, j := 0, len(infos)-1; i < j; i, j = i+1, j-1 {
+		infos[i], infos[j] = infos[j], infos[i]
+	}
+	return infos
+}
+
+// versions maps the names of the active plugins to their versions. It must be
+// called with m.mu held.
+func (m *PluginManager) versions() map[string]string {
+	versions := make(map[string]string, len(m.active))
+	for _, a := range m.active {
+		versions[a.plugin.Name()] = a.plugin.Version()
+	}
+	return versions
+}
+
+// index returns the index of the active plugin with the given name, or -1. It
+// must be called with m.mu held.
+func (m *PluginManager) index(name string) int {
+	for i, a := range m.active {
+		if a.plugin.Name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeHandlers removes the callbacks added by the plugin with the given name.
+func (m *PluginManager) removeHandlers(name string) int {
+	return m.hooks.RemoveOwned(name)
+}
@@ -0,0 +1,65 @@
+//go:build (linux || darwin || freebsd) && cgo
+
+package hooks
+
+import (
+	"fmt"
+	"plugin"
+	"strings"
+)
+
+// Load opens a Go plugin file built with -buildmode=plugin and activates the
+// Plugin it exports as PluginSymbol, recording the path it was loaded from.
+// When constraint is not empty, the version of the plugin must satisfy it.
+//
+// Go cannot unload a plugin file: deactivating its plugin removes its
+// callbacks, but the code stays in memory, and loading the same file again
+// returns the same Plugin.
+func (m *PluginManager) Load(path string, constraint string) (Plugin, error) {
+	file, err := plugin.Open(path)
+	if err != nil {
+		if strings.Contains(err.Error(), "different version of package") {
+			return nil, fmt.Errorf("load plugin %s: %w: %v", path, ErrPluginVersion, err)
+		}
+		return nil, fmt.Errorf("load plugin %s: %w", path, err)
+	}
+
+	sym, err := file.Lookup(PluginSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("load plugin %s: %w: %v", path, ErrPluginSymbol, err)
+	}
+
+	var p Plugin
+	switch sym := sym.(type) {
+	case *Plugin:
+		p = *sym
+	case func() Plugin:
+		p = sym()
+	default:
+		return nil, fmt.Errorf("load plugin %s: %w: %s is a %T, not a hooks.Plugin or a func() hooks.Plugin", path, ErrPluginSymbol, PluginSymbol, sym)
+	}
+	if p == nil {
+		return nil, fmt.Errorf("load plugin %s: %w: %s is nil", path, ErrPluginSymbol, PluginSymbol)
+	}
+
+	if constraint != "" {
+		v, err := parseVersion(p.Version())
+		if err != nil {
+			return nil, fmt.Errorf("load plugin %s: %w: %v", path, ErrPluginVersion, err)
+		}
+
+		ok, err := satisfies(v, constraint)
+		if err != nil {
+			return nil, fmt.Errorf("load plugin %s: %w", path, err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("load plugin %s: %w: %s does not satisfy %q", path, ErrPluginVersion, p.Version(), constraint)
+		}
+	}
+
+	if err := m.activateFrom(path, []Plugin{p}); err != nil {
+		return nil, fmt.Errorf("load plugin %s: %w", path, err)
+	}
+
+	return p, nil
+}
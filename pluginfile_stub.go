@@ -0,0 +1,11 @@
+//go:build !((linux || darwin || freebsd) && cgo)
+
+package hooks
+
+import "fmt"
+
+// Load returns ErrPluginsUnsupported, as Go plugins are only supported on
+// Linux, FreeBSD and macOS with cgo enabled.
+func (m *PluginManager) Load(path string, constraint string) (Plugin, error) {
+	return nil, fmt.Errorf("load plugin %s: %w", path, ErrPluginsUnsupported)
+}
@@ -0,0 +1,59 @@
+package hooks
+
+// SetActionErrorPolicy sets the ErrorPolicy of an action.
+func (e *Engine) SetActionErrorPolicy(hookName string, policy ErrorPolicy) {
+	setErrorPolicy(e.actions, hookName, policy)
+}
+
+// SetFilterErrorPolicy sets the ErrorPolicy of a filter.
+func (e *Engine) SetFilterErrorPolicy(hookName string, policy ErrorPolicy) {
+	setErrorPolicy(e.filters, hookName, policy)
+}
+
+// setErrorPolicy sets the ErrorPolicy of a hook. An empty hook name sets the
+// policy of every hook without its own policy.
+func setErrorPolicy(hooks *Hooks, hookName string, policy ErrorPolicy) {
+	hooks.configure(func(config *hookConfig) {
+		config.errorPolicies[hookName] = policy
+	})
+}
+
+// errorPolicy returns the ErrorPolicy of a hook.
+func (config *hookConfig) errorPolicy(hookName string) ErrorPolicy {
+	if len(config.errorPolicies) == 0 {
+		return StopOnError
+	}
+	if policy, ok := config.errorPolicies[hookName]; ok {
+		return policy
+	}
+	return config.errorPolicies[""]
+}
+
+// SetActionPanicPolicy sets the PanicPolicy of an action.
+func (e *Engine) SetActionPanicPolicy(hookName string, policy PanicPolicy) {
+	setPanicPolicy(e.actions, hookName, policy)
+}
+
+// SetFilterPanicPolicy sets the PanicPolicy of a filter.
+func (e *Engine) SetFilterPanicPolicy(hookName string, policy PanicPolicy) {
+	setPanicPolicy(e.filters, hookName, policy)
+}
+
+// setPanicPolicy sets the PanicPolicy of a hook. An empty hook name sets the
+// policy of every hook without its own policy.
+func setPanicPolicy(hooks *Hooks, hookName string, policy PanicPolicy) {
+	hooks.configure(func(config *hookConfig) {
+		config.panicPolicies[hookName] = policy
+	})
+}
+
+// panicPolicy returns the PanicPolicy of a hook.
+func (config *hookConfig) panicPolicy(hookName string) PanicPolicy {
+	if len(config.panicPolicies) == 0 {
+		return PropagatePanic
+	}
+	if policy, ok := config.panicPolicies[hookName]; ok {
+		return policy
+	}
+	return config.panicPolicies[""]
+}
@@ -0,0 +1,573 @@
+package hooks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// ErrProcessExited is returned by the callbacks of a ProcessPlugin while its
+// process is not running.
+var ErrProcessExited = errors.New("plugin process exited")
+
+// ProcessOptions configures a ProcessPlugin.
+type ProcessOptions struct {
+	// Command is the program to run, and Args its arguments.
+	Command string
+	Args    []string
+	// Env is added to the environment of the process.
+	Env []string
+	// Stderr receives the standard error of the process. It defaults to
+	// os.Stderr.
+	Stderr io.Writer
+	// CallTimeout limits the time the process has to run a callback or to
+	// answer the handshake. It defaults to 10 seconds.
+	CallTimeout time.Duration
+	// MaxRestarts is the number of times the process is started again after
+	// exiting unexpectedly.
+	MaxRestarts int
+}
+
+// ProcessPlugin is a Plugin running in a child process, served with
+// ServeProcessPlugin, so that it cannot crash the program. The process
+// announces its callbacks on startup, and the ProcessPlugin adds a proxy for
+// each of them which sends the arguments to the process and returns the
+// value it answers.
+//
+// Arguments and values are encoded as JSON, so they arrive as the types
+// encoding/json decodes into an interface{}: a number is a float64, an
+// object a map[string]interface{}, and so on. Callbacks of the process which
+// fail, panic or take longer than the CallTimeout make their proxy return an
+// error, and the value of a filter is kept.
+//
+// When the process exits unexpectedly, it is started again up to
+// MaxRestarts times. After that, the proxies are removed and the
+// plugin_process_exited action is triggered, passing values for the name of
+// the plugin and the error the process exited with. Each restart triggers the
+// plugin_process_restarted action, passing values for the name of the plugin
+// and the number of restarts so far.
+type ProcessPlugin struct {
+	options      ProcessOptions
+	announcement processAnnouncement
+
+	mu       sync.Mutex
+	conn     *processConn
+	core     *Core
+	subs     []*Subscription
+	restarts int
+	stopped  bool
+}
+
+// StartProcessPlugin starts the process of a ProcessPlugin and waits for it to
+// announce its callbacks. The plugin is then ready to be activated.
+func StartProcessPlugin(options ProcessOptions) (*ProcessPlugin, error) {
+	if options.Stderr == nil {
+		options.Stderr = os.Stderr
+	}
+	if options.CallTimeout <= 0 {
+		options.CallTimeout = 10 * time.Second
+	}
+
+	p := &ProcessPlugin{options: options}
+
+	conn, announcement, err := p.start()
+	if err != nil {
+		return nil, err
+	}
+
+	p.conn = conn
+	p.announcement = announcement
+
+	return p, nil
+}
+
+// Name returns the name announced by the process.
+func (p *ProcessPlugin) Name() string {
+	return p.announcement.Name
+}
+
+// Version returns the version announced by the process.
+func (p *ProcessPlugin) Version() string {
+	return p.announcement.Version
+}
+
+// Activate adds a proxy to core for each callback announced by the process.
+func (p *ProcessPlugin) Activate(core *Core) error {
+	p.mu.Lock()
+	if p.stopped || p.conn == nil {
+		p.mu.Unlock()
+		return fmt.Errorf("plugin %s: %w", p.Name(), ErrProcessExited)
+	}
+	p.core = core
+	conn := p.conn
+	announcement := p.announcement
+	p.mu.Unlock()
+
+	subs := p.subscribe(core, announcement)
+
+	p.mu.Lock()
+	if p.stopped {
+		// Deactivated in the meantime.
+		p.mu.Unlock()
+		for _, s := range subs {
+			s.Remove()
+		}
+		return fmt.Errorf("plugin %s: %w", p.Name(), ErrProcessExited)
+	}
+	p.subs = subs
+	p.mu.Unlock()
+
+	go p.watch(conn)
+
+	return nil
+}
+
+// Deactivate removes the proxies and stops the process.
+func (p *ProcessPlugin) Deactivate(core *Core) error {
+	p.mu.Lock()
+	p.stopped = true
+	conn := p.conn
+	subs := p.subs
+	p.conn = nil
+	p.subs = nil
+	p.mu.Unlock()
+
+	for _, s := range subs {
+		s.Remove()
+	}
+
+	if conn != nil {
+		conn.close(p.options.CallTimeout)
+	}
+	return nil
+}
+
+// start starts the process and performs the handshake.
+func (p *ProcessPlugin) start() (*processConn, processAnnouncement, error) {
+	var announcement processAnnouncement
+
+	conn, err := startProcess(p.options)
+	if err != nil {
+		return nil, announcement, fmt.Errorf("start plugin process %s: %w", p.options.Command, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.options.CallTimeout)
+	defer cancel()
+
+	if err := conn.call(ctx, "hooks.handshake", nil, &announcement); err != nil {
+		conn.close(p.options.CallTimeout)
+		return nil, announcement, fmt.Errorf("start plugin process %s: handshake: %w", p.options.Command, err)
+	}
+
+	return conn, announcement, nil
+}
+
+// subscribe adds the proxies of the announced callbacks to core. It must be
+// called without p.mu held, since the callbacks of HookAdded may run the
+// proxies, which lock it.
+func (p *ProcessPlugin) subscribe(core *Core, announcement processAnnouncement) []*Subscription {
+	subs := make([]*Subscription, 0, len(announcement.Hooks))
+
+	for i, hook := range announcement.Hooks {
+		subscribe := core.SubscribeActionE
+		if hook.Kind == KindFilter {
+			subscribe = core.SubscribeFilterE
+		}
+
+		index := i
+		subs = append(subs, subscribe(hook.Hook, hook.Namespace, func(ctx context.Context, args ...interface{}) (interface{}, error) {
+			return p.call(ctx, index, args)
+		}, hook.Priority))
+	}
+
+	return subs
+}
+
+// call runs the callback at the given index of the announcement in the
+// process.
+func (p *ProcessPlugin) call(ctx context.Context, index int, args []interface{}) (interface{}, error) {
+	p.mu.Lock()
+	conn := p.conn
+	p.mu.Unlock()
+
+	if conn == nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.Name(), ErrProcessExited)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.options.CallTimeout)
+	defer cancel()
+
+	var result processCallResult
+	if err := conn.call(ctx, "hooks.call", processCallParams{Index: index, Args: args}, &result); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.Name(), err)
+	}
+
+	if result.Stop {
+		return Stop(result.Value), nil
+	}
+	return result.Value, nil
+}
+
+// watch waits for the process to exit, then starts it again or removes the
+// proxies.
+func (p *ProcessPlugin) watch(conn *processConn) {
+	<-conn.done
+
+	for {
+		p.mu.Lock()
+		if p.stopped || p.conn != conn {
+			p.mu.Unlock()
+			return
+		}
+		if p.restarts >= p.options.MaxRestarts {
+			break
+		}
+		p.restarts++
+		p.mu.Unlock()
+
+		// The lock is not held while starting the process, so that the
+		// proxies fail right away in the meantime.
+		next, announcement, err := p.start()
+		if err != nil {
+			continue
+		}
+
+		p.mu.Lock()
+		if p.stopped {
+			p.mu.Unlock()
+			next.close(p.options.CallTimeout)
+			return
+		}
+
+		// The process may announce other callbacks than before.
+		p.conn = next
+		p.announcement.Hooks = announcement.Hooks
+		restarts := p.restarts
+		core := p.core
+		p.mu.Unlock()
+
+		added := p.subscribe(core, announcement)
+
+		p.mu.Lock()
+		if p.stopped {
+			// Deactivated in the meantime, which removed the previous
+			// proxies.
+			p.mu.Unlock()
+			for _, s := range added {
+				s.Remove()
+			}
+			return
+		}
+		subs := p.subs
+		p.subs = added
+		p.mu.Unlock()
+
+		for _, s := range subs {
+			s.Remove()
+		}
+
+		core.DoAction("plugin_process_restarted", p.Name(), restarts)
+
+		go p.watch(next)
+		return
+	}
+
+	// p.mu is held.
+	subs := p.subs
+	core := p.core
+	p.conn = nil
+	p.subs = nil
+	p.mu.Unlock()
+
+	for _, s := range subs {
+		s.Remove()
+	}
+
+	core.DoAction("plugin_process_exited", p.Name(), conn.err)
+}
+
+// The process and its host exchange JSON-RPC 2.0 messages, one per line. The
+// host sends requests to the process, which answers each of them:
+//
+//   - hooks.handshake takes no parameters and returns a processAnnouncement.
+//   - hooks.call takes processCallParams and returns a processCallResult.
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// JSON-RPC error codes.
+const (
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcCallbackFailed = -32000
+)
+
+// processAnnouncement is the result of hooks.handshake.
+type processAnnouncement struct {
+	Name    string        `json:"name"`
+	Version string        `json:"version"`
+	Hooks   []processHook `json:"hooks"`
+}
+
+// processHook is a callback announced by the process.
+type processHook struct {
+	Kind      Kind   `json:"kind"`
+	Hook      string `json:"hook"`
+	Namespace string `json:"namespace"`
+	Priority  int    `json:"priority"`
+}
+
+// processCallParams are the parameters of hooks.call. Index is the index of
+// the callback in the announcement.
+type processCallParams struct {
+	Index int           `json:"index"`
+	Args  []interface{} `json:"args"`
+}
+
+// processCallResult is the result of hooks.call. Stop is set when the
+// callback returned Stop.
+type processCallResult struct {
+	Value interface{} `json:"value"`
+	Stop  bool        `json:"stop,omitempty"`
+}
+
+// processConn is a running plugin process.
+type processConn struct {
+	cmd   *exec.Cmd
+	stdin *os.File
+
+	// writing is held by the request being written to stdin. It is a
+	// channel, so that a request can stop waiting for it when its context
+	// is done.
+	writing chan struct{}
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan rpcResponse
+
+	// done is closed once the process exited, after err is set.
+	done chan struct{}
+	err  error
+}
+
+func startProcess(options ProcessOptions) (*processConn, error) {
+	cmd := exec.Command(options.Command, options.Args...)
+	cmd.Env = append(os.Environ(), options.Env...)
+	cmd.Stderr = options.Stderr
+
+	// The pipe is made here rather than with StdinPipe, so that writes to
+	// it can be given a deadline.
+	stdinReader, stdin, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin = stdinReader
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		stdinReader.Close()
+		stdin.Close()
+		return nil, err
+	}
+
+	err = cmd.Start()
+	stdinReader.Close()
+	if err != nil {
+		stdin.Close()
+		return nil, err
+	}
+
+	conn := &processConn{
+		cmd:     cmd,
+		stdin:   stdin,
+		writing: make(chan struct{}, 1),
+		pending: map[uint64]chan rpcResponse{},
+		done:    make(chan struct{}),
+	}
+
+	go conn.read(stdout)
+
+	return conn, nil
+}
+
+// read dispatches the responses of the process until it exits.
+func (conn *processConn) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		var response rpcResponse
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			continue
+		}
+
+		conn.mu.Lock()
+		ch, ok := conn.pending[response.ID]
+		delete(conn.pending, response.ID)
+		conn.mu.Unlock()
+
+		if ok {
+			ch <- response
+		}
+	}
+
+	err := conn.cmd.Wait()
+	if err == nil {
+		err = io.EOF
+	}
+
+	conn.mu.Lock()
+	conn.err = err
+	conn.pending = nil
+	conn.mu.Unlock()
+
+	close(conn.done)
+}
+
+// call sends a request to the process and decodes its result into result.
+func (conn *processConn) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	request := rpcRequest{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		request.Params = raw
+	}
+
+	ch := make(chan rpcResponse, 1)
+
+	conn.mu.Lock()
+	if conn.pending == nil {
+		conn.mu.Unlock()
+		return ErrProcessExited
+	}
+	conn.nextID++
+	request.ID = conn.nextID
+	conn.pending[request.ID] = ch
+	conn.mu.Unlock()
+
+	line, err := json.Marshal(request)
+	if err == nil {
+		err = conn.write(ctx, append(line, '\n'))
+	}
+
+	if err != nil {
+		conn.forget(request.ID)
+		return err
+	}
+
+	select {
+	case response := <-ch:
+		if response.Error != nil {
+			return response.Error
+		}
+		return json.Unmarshal(response.Result, result)
+	case <-conn.done:
+		return fmt.Errorf("%w: %v", ErrProcessExited, conn.err)
+	case <-ctx.Done():
+		conn.forget(request.ID)
+		return ctx.Err()
+	}
+}
+
+// write writes a line to the standard input of the process, giving up when
+// ctx is done. A process which stops reading its standard input cannot block
+// its callers, nor the responses to the other requests.
+func (conn *processConn) write(ctx context.Context, line []byte) error {
+	select {
+	case conn.writing <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-conn.done:
+		return fmt.Errorf("%w: %v", ErrProcessExited, conn.err)
+	}
+	defer func() { <-conn.writing }()
+
+	// The write is interrupted by a deadline once ctx is done.
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			// Where pipes have no deadlines, the pipe is closed instead.
+			if conn.stdin.SetWriteDeadline(time.Now()) != nil {
+				conn.stdin.Close()
+			}
+		case <-stop:
+		}
+	}()
+
+	n, err := conn.stdin.Write(line)
+
+	close(stop)
+	<-stopped
+	conn.stdin.SetWriteDeadline(time.Time{})
+
+	if err == nil {
+		return nil
+	}
+
+	// The process would read the rest of the line as the start of the next
+	// request, so it is stopped instead, as if it had crashed.
+	if n > 0 {
+		conn.stdin.Close()
+		conn.cmd.Process.Kill()
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case errors.Is(err, os.ErrClosed):
+		return fmt.Errorf("%w: %v", ErrProcessExited, err)
+	}
+	return err
+}
+
+func (conn *processConn) forget(id uint64) {
+	conn.mu.Lock()
+	delete(conn.pending, id)
+	conn.mu.Unlock()
+}
+
+// close closes the standard input of the process, which makes
+// ServeProcessPlugin return, and kills the process if it is still running
+// after the timeout.
+func (conn *processConn) close(timeout time.Duration) {
+	conn.stdin.Close()
+
+	select {
+	case <-conn.done:
+	case <-time.After(timeout):
+		conn.cmd.Process.Kill()
+		<-conn.done
+	}
+}
@@ -0,0 +1,127 @@
+package hooks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// ServeProcessPlugin runs a Plugin in the process started by a ProcessPlugin.
+// It activates the plugin on a new Core, announces the callbacks it added,
+// then runs them as requested by the host on the standard input and output
+// until the standard input is closed. Callbacks added with a hook pattern are
+// not announced.
+func ServeProcessPlugin(p Plugin) error {
+	return serveProcessPlugin(p, os.Stdin, os.Stdout)
+}
+
+func serveProcessPlugin(p Plugin, r io.Reader, w io.Writer) error {
+	core := CreateHooks()
+	if err := p.Activate(&core); err != nil {
+		return fmt.Errorf("activate plugin %q: %w", p.Name(), err)
+	}
+	defer p.Deactivate(&core)
+
+	announcement := processAnnouncement{Name: p.Name(), Version: p.Version()}
+	var handlers []Handler
+
+	for _, hooks := range []*Hooks{core.engine.actions, core.engine.filters} {
+		hooks.mu.RLock()
+		for _, hookName := range hooks.names() {
+			for _, handler := range hooks.Hooks[hookName].Handlers {
+				if handler.Pattern != "" {
+					continue
+				}
+
+				announcement.Hooks = append(announcement.Hooks, processHook{
+					Kind:      hooks.kind,
+					Hook:      hookName,
+					Namespace: handler.Namespace,
+					Priority:  handler.Priority,
+				})
+				handlers = append(handlers, handler)
+			}
+		}
+		hooks.mu.RUnlock()
+	}
+
+	var mu sync.Mutex
+	respond := func(response rpcResponse) {
+		response.JSONRPC = "2.0"
+		line, err := json.Marshal(response)
+		if err != nil {
+			line, _ = json.Marshal(rpcResponse{
+				JSONRPC: "2.0",
+				ID:      response.ID,
+				Error:   &rpcError{Code: rpcCallbackFailed, Message: err.Error()},
+			})
+		}
+
+		mu.Lock()
+		w.Write(append(line, '\n'))
+		mu.Unlock()
+	}
+
+	var calls sync.WaitGroup
+	defer calls.Wait()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		var request rpcRequest
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			continue
+		}
+
+		switch request.Method {
+		case "hooks.handshake":
+			result, _ := json.Marshal(announcement)
+			respond(rpcResponse{ID: request.ID, Result: result})
+
+		case "hooks.call":
+			var params processCallParams
+			if err := json.Unmarshal(request.Params, &params); err != nil || params.Index < 0 || params.Index >= len(handlers) {
+				respond(rpcResponse{ID: request.ID, Error: &rpcError{Code: rpcInvalidParams, Message: "invalid callback"}})
+				continue
+			}
+
+			// Callbacks run concurrently, as they may in the host.
+			calls.Add(1)
+			go func(id uint64, handler Handler, hookName string, args []interface{}) {
+				defer calls.Done()
+
+				value, panicErr, err := handler.callRecover(context.Background(), hookName, args)
+				switch {
+				case panicErr != nil:
+					respond(rpcResponse{ID: id, Error: &rpcError{Code: rpcCallbackFailed, Message: panicErr.Error()}})
+					return
+				case err != nil:
+					respond(rpcResponse{ID: id, Error: &rpcError{Code: rpcCallbackFailed, Message: err.Error()}})
+					return
+				}
+
+				result := processCallResult{Value: value}
+				if s, ok := value.(stopped); ok {
+					result = processCallResult{Value: s.value, Stop: true}
+				}
+
+				raw, err := json.Marshal(result)
+				if err != nil {
+					respond(rpcResponse{ID: id, Error: &rpcError{Code: rpcCallbackFailed, Message: err.Error()}})
+					return
+				}
+				respond(rpcResponse{ID: id, Result: raw})
+			}(request.ID, handlers[params.Index], announcement.Hooks[params.Index].Hook, params.Args)
+
+		default:
+			respond(rpcResponse{ID: request.ID, Error: &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + request.Method}})
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,106 @@
+package hooks
+
+import "sort"
+
+// The methods below edit the handler lists of a Hooks registry and publish
+// them to running hooks. They must be called with hooks.mu held.
+
+// insertHandler inserts a handler into the list of the given hook after all
+// the handlers with the same or a lower priority. When callbacks of the hook
+// have an Order, the list is then sorted again to satisfy it. If that is not
+// possible, even for a handler without an Order whose namespace completes a
+// cycle, the handler is not inserted and an error wrapping ErrOrderCycle is
+// returned.
+func (hooks *Hooks) insertHandler(hookName string, handler Handler) error {
+	if err := hooks.placeHandler(hookName, handler); err != nil {
+		hooks.removeHandlerAt(hookName, hooks.handlerIndex(hookName, handler.id))
+		return err
+	}
+
+	hooks.publish(hookName)
+	return nil
+}
+
+// placeHandler is like insertHandler, but leaves the handler in place when the
+// handlers cannot be sorted, and does not publish them.
+func (hooks *Hooks) placeHandler(hookName string, handler Handler) error {
+	if _, ok := hooks.Hooks[hookName]; !ok {
+		hooks.addEntry(hookName)
+	}
+
+	entry := hooks.Hooks[hookName]
+	handlers := entry.Handlers
+
+	i := len(handlers)
+	for ; i > 0; i-- {
+		if handler.Priority >= handlers[i-1].Priority {
+			break
+		}
+	}
+
+	if i == len(handlers) {
+		handlers = append(handlers, handler)
+	} else {
+		// Otherwise, insert before index.
+		handlers = insert(handlers, i, handler)
+	}
+
+	entry.Handlers = handlers
+	hooks.Hooks[hookName] = entry
+
+	return hooks.sortHandlers(hookName)
+}
+
+// addEntry creates the entry of a hook, which starts with the callbacks added
+// with a hook pattern matching its name.
+func (hooks *Hooks) addEntry(hookName string) {
+	hooks.Hooks[hookName] = Handlers{
+		Handlers: []Handler{},
+		Runs:     0,
+	}
+
+	// The callbacks added with a pattern have no Order, so they cannot
+	// contradict each other.
+	for _, handler := range hooks.patterns {
+		if matchHookName(handler.Pattern, hookName) {
+			hooks.insertHandler(hookName, handler)
+		}
+	}
+
+	hooks.publish(hookName)
+}
+
+// removeHandlerAt removes the handler at index i from the list of the given
+// hook.
+func (hooks *Hooks) removeHandlerAt(hookName string, i int) Handler {
+	entry := hooks.Hooks[hookName]
+	handler := entry.Handlers[i]
+
+	entry.Handlers = append(entry.Handlers[:i], entry.Handlers[i+1:]...)
+	hooks.Hooks[hookName] = entry
+
+	hooks.publish(hookName)
+
+	return handler
+}
+
+// handlerIndex returns the index of the handler with the given id in the list
+// of the given hook, or -1.
+func (hooks *Hooks) handlerIndex(hookName string, id uint64) int {
+	for i, handler := range hooks.Hooks[hookName].Handlers {
+		if handler.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// names returns the names of the hooks of a registry in alphabetical order.
+func (hooks *Hooks) names() []string {
+	names := make([]string, 0, len(hooks.Hooks))
+	for hookName := range hooks.Hooks {
+		names = append(names, hookName)
+	}
+	sort.Strings(names)
+	return names
+}
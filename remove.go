@@ -0,0 +1,64 @@
+package hooks
+
+// RemoveAction removes the callbacks of an action added with namespace.
+func (e *Engine) RemoveAction(hookName string, namespace string) int {
+	return e.removeHook(e.actions, hookName, namespace, false)
+}
+
+// RemoveFilter removes the callbacks of a filter added with namespace.
+func (e *Engine) RemoveFilter(hookName string, namespace string) int {
+	return e.removeHook(e.filters, hookName, namespace, false)
+}
+
+// RemoveAllActions removes every callback of an action.
+func (e *Engine) RemoveAllActions(hookName string, namespace string) int {
+	return e.removeHook(e.actions, hookName, namespace, true)
+}
+
+// RemoveAllFilters removes every callback of a filter.
+func (e *Engine) RemoveAllFilters(hookName string, namespace string) int {
+	return e.removeHook(e.filters, hookName, namespace, true)
+}
+
+// removeHook removes a specified hook or all hooks by the given name.
+func (e *Engine) removeHook(hooks *Hooks, hookName string, namespace string, removeAll bool) int {
+	handlersRemoved, ok := hooks.removeNamespace(hookName, namespace, removeAll)
+	if !ok {
+		return handlersRemoved
+	}
+
+	if hookName != "HookRemoved" {
+		e.DoAction("HookRemoved", hookName, namespace)
+	}
+
+	return handlersRemoved
+}
+
+// removeNamespace removes the handlers of a hook added with namespace, or all
+// of them if removeAll is set. It returns the number of handlers removed, and
+// whether the hook exists.
+func (hooks *Hooks) removeNamespace(hookName string, namespace string, removeAll bool) (int, bool) {
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+
+	entry, ok := hooks.Hooks[hookName]
+	if !ok {
+		return 0, false
+	}
+
+	kept := []Handler{}
+	for _, handler := range entry.Handlers {
+		if !removeAll && handler.Namespace != namespace {
+			kept = append(kept, handler)
+		}
+	}
+
+	handlersRemoved := len(entry.Handlers) - len(kept)
+	entry.Handlers = kept
+	hooks.Hooks[hookName] = entry
+
+	// Running hooks go on with the handlers that are left.
+	hooks.publish(hookName)
+
+	return handlersRemoved, true
+}
@@ -0,0 +1,308 @@
+package hooks
+
+import (
+	"context"
+	"time"
+)
+
+// DoAction executes all callbacks registered to an action.
+func (e *Engine) DoAction(hookName string, args ...interface{}) interface{} {
+	result, _ := e.runHook(context.Background(), e.actions, hookName, false, args, nil)
+	return result
+}
+
+// ApplyFilters executes all callbacks registered to a filter and returns the
+// final value of the call chain.
+func (e *Engine) ApplyFilters(hookName string, args ...interface{}) interface{} {
+	result, _ := e.runHook(context.Background(), e.filters, hookName, true, args, nil)
+	return result
+}
+
+// runHook executes the callbacks registered to a hook in order, after
+// letting the "all" action observe the call. The context
+// is checked before every callback: once it is done, the remaining callbacks
+// are skipped and the context's error is returned along with the value of
+// the chain so far. Errors returned by callbacks are handled according to the
+// ErrorPolicy of the hook, and panics according to its PanicPolicy; a failed
+// filter callback never replaces the value. Callbacks running out of the
+// time given by the Timeouts of the hook are handled according to their
+// TimeoutPolicy. A callback can also end the chain early by returning Stop or
+// calling StopAction or StopFilter. The callbacks of a namespace whose circuit
+// breaker is open are skipped.
+//
+// When results is not nil, the value returned by each callback that ran is
+// appended to it.
+func (e *Engine) runHook(ctx context.Context, hooks *Hooks, hookName string, returnFirstArg bool, args []interface{}, results *[]interface{}) (interface{}, error) {
+	if e.observed() && !metaHooks[hookName] {
+		e.observe(ctx, hooks.kind, hookName, args)
+	}
+
+	return e.runState(ctx, hooks, hookName, hooks.state(hookName), returnFirstArg, args, results)
+}
+
+// runState is the part of runHook running the callbacks of a hook, given its
+// state.
+func (e *Engine) runState(ctx context.Context, hooks *Hooks, hookName string, state *hookState, returnFirstArg bool, args []interface{}, results *[]interface{}) (interface{}, error) {
+	state.runs.Add(1)
+
+	snapshot := state.handlers.Load()
+	if len(*snapshot) == 0 {
+		if returnFirstArg {
+			return args[0], nil
+		}
+		return nil, nil
+	}
+
+	config := hooks.dispatch.config.Load()
+
+	if concurrency := config.parallel[hookName]; concurrency != 0 && !returnFirstArg {
+		return nil, e.runParallel(ctx, hooks, hookName, snapshot, config, concurrency, args, results)
+	}
+
+	// The callbacks run below the markers of a slot describing the run, so
+	// that CurrentAction and the like find it from them.
+	slot := hooks.dispatch.group.acquire(hooks, hookName)
+	defer slot.release()
+
+	r := runner{
+		slot:           slot,
+		ctx:            ctx,
+		engine:         e,
+		hooks:          hooks,
+		state:          state,
+		snapshot:       snapshot,
+		config:         config,
+		returnFirstArg: returnFirstArg,
+		args:           args,
+		results:        results,
+	}
+	slot.mark(work{r: &r})
+
+	if returnFirstArg {
+		return args[0], r.err
+	}
+
+	return nil, r.err
+}
+
+// runner runs the callbacks of a hook.
+type runner struct {
+	slot           *slot
+	ctx            context.Context
+	engine         *Engine
+	hooks          *Hooks
+	state          *hookState
+	snapshot       *[]Handler
+	config         *hookConfig
+	returnFirstArg bool
+	args           []interface{}
+	results        *[]interface{}
+	// err holds the errors of the run once it is done.
+	err error
+}
+
+// execute runs the callbacks. It must not be inlined into the markers.
+//
+//go:noinline
+func (r *runner) execute() {
+	ctx, e, hooks, config, args := r.ctx, r.engine, r.hooks, r.config, r.args
+	hookName, run := r.slot.run.name, &r.slot.run
+
+	policy := config.errorPolicy(hookName)
+	panicPolicy := config.panicPolicy(hookName)
+	timeouts := config.timeoutsOf(hookName)
+
+	var deadline time.Time
+	if timeouts.Budget > 0 {
+		deadline = time.Now().Add(timeouts.Budget)
+	}
+
+	var errs []error
+
+	// The registry is never locked while the hook runs, so that callbacks
+	// are free to add, remove or run hooks themselves. The handlers are read
+	// from a snapshot, and the run moves on to the latest snapshot after each
+	// callback when the handlers changed in the meantime.
+	for snapshot, i := r.snapshot, 0; i < len(*snapshot); snapshot, i = r.state.next(snapshot, i) {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		r.slot.at(snapshot, i)
+
+		handler := &(*snapshot)[i]
+		if !handler.active() {
+			continue
+		}
+		timeout := config.callbackTimeout(handler.Namespace, timeouts)
+
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				err := e.budgetExhausted(hookName, timeouts.Budget)
+				if timeouts.Policy == AbortOnTimeout {
+					errs = append(errs, err)
+				}
+				break
+			}
+
+			if timeout <= 0 || remaining < timeout {
+				timeout = remaining
+			}
+		}
+
+		// The outcome of the callback is only recorded when the registry has
+		// circuit breakers, which most do not.
+		var o outcome
+		switch {
+		case hooks.breakers.enabled.Load():
+			allowed, change := hooks.breakers.allow(hookName, handler.Namespace)
+			change.report(e)
+
+			if !allowed {
+				continue
+			}
+			o = r.invoke(handler, timeout, panicPolicy != PropagatePanic)
+		case timeout > 0:
+			o = e.invokeTimed(ctx, hooks, hookName, *handler, panicPolicy != PropagatePanic, args, timeout)
+		default:
+			o = e.invokeHandler(ctx, hookName, handler, panicPolicy != PropagatePanic, args)
+		}
+
+		if o.timedOut {
+			if timeouts.Policy == AbortOnTimeout {
+				errs = append(errs, o.err)
+				break
+			}
+			continue
+		}
+
+		if o.err == nil && o.panicErr == nil && r.returnFirstArg {
+			args[0] = o.result
+		}
+
+		if r.results != nil && o.panicErr == nil {
+			*r.results = append(*r.results, o.result)
+		}
+
+		if o.stop {
+			run.stopped.Store(true)
+		}
+
+		if o.panicErr != nil {
+			errs = append(errs, o.panicErr)
+
+			if panicPolicy == AbortOnPanic {
+				break
+			}
+		} else if o.err != nil && policy != IgnoreErrors {
+			errs = append(errs, o.err)
+
+			if policy == StopOnError {
+				break
+			}
+		}
+
+		if run.stopped.Load() {
+			break
+		}
+	}
+
+	r.err = joinErrors(errs)
+}
+
+// invoke calls the callback of a handler as invokeHandler does, or as
+// invokeTimed does when timeout is set, and records its outcome in the
+// circuit breaker of its namespace. A panic unwinding through the run counts
+// as a failure, so that a half-open breaker does not wait for its trial call
+// forever.
+func (r *runner) invoke(handler *Handler, timeout time.Duration, recoverPanics bool) (o outcome) {
+	failed := true
+	defer func() {
+		r.hooks.breakers.record(r.slot.run.name, handler.Namespace, failed).report(r.engine)
+	}()
+
+	if timeout > 0 {
+		o = r.engine.invokeTimed(r.ctx, r.hooks, r.slot.run.name, *handler, recoverPanics, r.args, timeout)
+	} else {
+		o = r.engine.invokeHandler(r.ctx, r.slot.run.name, handler, recoverPanics, r.args)
+	}
+
+	failed = o.failed()
+	return o
+}
+
+// observe runs the callbacks of the "all" action, if any, before a hook of
+// the given kind runs. They receive the name of the hook, its Kind and a copy
+// of its arguments.
+func (e *Engine) observe(ctx context.Context, kind Kind, hookName string, args []interface{}) {
+	if !e.observed() {
+		return
+	}
+
+	e.runHook(ctx, e.actions, "all", false, []interface{}{hookName, kind, append([]interface{}(nil), args...)}, nil)
+}
+
+// observed returns whether the "all" action has callbacks.
+func (e *Engine) observed() bool {
+	state := e.actions.dispatch.observers.Load()
+	return state != nil && len(*state.handlers.Load()) > 0
+}
+
+// outcome is the result of running a single callback.
+type outcome struct {
+	result interface{}
+	// err is the error returned by the callback, as a HookError.
+	err error
+	// panicErr is set when the callback panicked and the panic was
+	// recovered.
+	panicErr *PanicError
+	// stop is set when the callback returned Stop.
+	stop bool
+	// timedOut is set when the callback did not return in time, in which
+	// case err is a TimeoutError.
+	timedOut bool
+}
+
+// failed returns whether the callback failed, which counts against its
+// circuit breaker.
+func (o outcome) failed() bool {
+	return o.err != nil || o.panicErr != nil
+}
+
+// invokeHandler runs the callback of a handler, recovering from its panics
+// if asked to, in which case the HookFailed action is triggered.
+func (e *Engine) invokeHandler(ctx context.Context, hookName string, handler *Handler, recoverPanics bool, args []interface{}) outcome {
+	var o outcome
+	var err error
+
+	if recoverPanics {
+		o.result, o.panicErr, err = handler.callRecover(ctx, hookName, args)
+	} else {
+		o.result, err = handler.call(ctx, args)
+	}
+
+	if o.panicErr != nil {
+		if hookName != "HookFailed" {
+			e.DoAction("HookFailed", hookName, handler.Namespace, handler.Priority, o.panicErr)
+		}
+		return o
+	}
+
+	if s, ok := o.result.(stopped); ok {
+		o.result = s.value
+		o.stop = true
+	}
+
+	if err != nil {
+		o.err = &HookError{
+			Hook:      hookName,
+			Namespace: handler.Namespace,
+			Priority:  handler.Priority,
+			Err:       err,
+		}
+	}
+
+	return o
+}
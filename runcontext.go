@@ -0,0 +1,17 @@
+package hooks
+
+import "context"
+
+// DoActionContext executes all callbacks registered to an action, stopping
+// early if the context is done.
+func (e *Engine) DoActionContext(ctx context.Context, hookName string, args ...interface{}) error {
+	_, err := e.runHook(ctx, e.actions, hookName, false, args, nil)
+	return err
+}
+
+// ApplyFiltersContext executes all callbacks registered to a filter, stopping
+// early if the context is done. The value of the chain so far is returned
+// along with the context's error.
+func (e *Engine) ApplyFiltersContext(ctx context.Context, hookName string, args ...interface{}) (interface{}, error) {
+	return e.runHook(ctx, e.filters, hookName, true, args, nil)
+}
@@ -0,0 +1,16 @@
+package hooks
+
+import "context"
+
+// DoActionE executes all callbacks registered to an action and returns the
+// errors of the callbacks.
+func (e *Engine) DoActionE(hookName string, args ...interface{}) error {
+	_, err := e.runHook(context.Background(), e.actions, hookName, false, args, nil)
+	return err
+}
+
+// ApplyFiltersE executes all callbacks registered to a filter and returns
+// the final value along with the errors of the callbacks.
+func (e *Engine) ApplyFiltersE(hookName string, args ...interface{}) (interface{}, error) {
+	return e.runHook(context.Background(), e.filters, hookName, true, args, nil)
+}
@@ -0,0 +1,159 @@
+package hooks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// version is a semantic version, such as "2.1.0" or "v1.0.0-beta.1". Build
+// metadata is ignored.
+type version struct {
+	major, minor, patch int
+	pre                 []string
+}
+
+// parseVersion parses a semantic version. The "v" prefix is optional, and so
+// are the minor and patch numbers, which default to 0.
+func parseVersion(s string) (version, error) {
+	var v version
+
+	str := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(str, '+'); i >= 0 {
+		str = str[:i]
+	}
+	if i := strings.IndexByte(str, '-'); i >= 0 {
+		v.pre = strings.Split(str[i+1:], ".")
+		str = str[:i]
+	}
+
+	parts := strings.Split(str, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+
+	numbers := []*int{&v.major, &v.minor, &v.patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		*numbers[i] = n
+	}
+
+	return v, nil
+}
+
+// compare returns -1, 0 or 1 depending on whether v is lower than, equal to or
+// greater than w. A pre-release is lower than the release it precedes.
+func (v version) compare(w version) int {
+	for _, d := range [][2]int{{v.major, w.major}, {v.minor, w.minor}, {v.patch, w.patch}} {
+		if d[0] != d[1] {
+			return cmpInt(d[0], d[1])
+		}
+	}
+
+	switch {
+	case len(v.pre) == 0 && len(w.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(w.pre) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.pre) && i < len(w.pre); i++ {
+		a, errA := strconv.Atoi(v.pre[i])
+		b, errB := strconv.Atoi(w.pre[i])
+
+		switch {
+		case errA == nil && errB == nil:
+			if a != b {
+				return cmpInt(a, b)
+			}
+		case errA == nil:
+			// Numeric identifiers are lower than alphanumeric ones.
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(v.pre[i], w.pre[i]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return cmpInt(len(v.pre), len(w.pre))
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// satisfies reports whether a version satisfies a constraint, made of
+// comparisons separated by commas or spaces which must all hold, such as
+// ">=2.1, <3". The operators are =, !=, >, >=, <, <=, ^ (same major version,
+// or same minor version below 1.0.0) and ~ (same minor version). A version
+// without operator must be equal, and an empty constraint or "*" matches any
+// version.
+func satisfies(v version, constraint string) (bool, error) {
+	fields := strings.FieldsFunc(constraint, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if field == "*" {
+			continue
+		}
+
+		operand := strings.TrimLeft(field, "<>=!^~")
+		op := field[:len(field)-len(operand)]
+		if operand == "" && i+1 < len(fields) {
+			// The operator is separated from its version, as in ">= 2.1".
+			i++
+			operand = fields[i]
+		}
+
+		w, err := parseVersion(operand)
+		if err != nil {
+			return false, fmt.Errorf("invalid constraint %q: %w", constraint, err)
+		}
+
+		c := v.compare(w)
+
+		var ok bool
+		switch op {
+		case "", "=", "==":
+			ok = c == 0
+		case "!=":
+			ok = c != 0
+		case ">":
+			ok = c > 0
+		case ">=":
+			ok = c >= 0
+		case "<":
+			ok = c < 0
+		case "<=":
+			ok = c <= 0
+		case "^":
+			ok = c >= 0 && v.major == w.major && (w.major > 0 || v.minor == w.minor)
+		case "~":
+			ok = c >= 0 && v.major == w.major && v.minor == w.minor
+		default:
+			return false, fmt.Errorf("invalid constraint %q: unknown operator %q", constraint, op)
+		}
+
+		if !ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
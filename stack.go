@@ -0,0 +1,546 @@
+package hooks
+
+import (
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// hookRun is a running hook. Its fields may be read from the goroutines
+// started for its callbacks, once its slot is shared with them.
+type hookRun struct {
+	name  string
+	kind  Kind
+	group *runGroup
+	// index is the index of the handler running, in handlers or, if it is
+	// set, in moved. It is written atomically once the slot is shared.
+	index    int64
+	handlers *[]Handler
+	// moved holds the handlers of the hook the run moved on to after its
+	// slot was shared, if they changed.
+	moved atomic.Pointer[[]Handler]
+	// stopped is set once the hook is stopped.
+	stopped atomic.Bool
+}
+
+// stackInfo returns a HookInfo describing the run at index i of a stack.
+func stackInfo(stack []*hookRun, i int) HookInfo {
+	run := stack[i]
+	info := HookInfo{
+		Name:         run.name,
+		Kind:         run.kind,
+		CurrentIndex: int(atomic.LoadInt64(&run.index)),
+		Stopped:      run.stopped.Load(),
+		Depth:        i,
+	}
+
+	if i > 0 {
+		info.Parent = stack[i-1].name
+	}
+
+	snapshot := run.moved.Load()
+	if snapshot == nil {
+		snapshot = run.handlers
+	}
+	if snapshot != nil && info.CurrentIndex < len(*snapshot) {
+		handler := (*snapshot)[info.CurrentIndex]
+		info.Namespace = handler.Namespace
+		info.Priority = handler.Priority
+	}
+
+	return info
+}
+
+// stack returns the actions and filters of the Core running in the calling
+// goroutine, outermost first.
+func (hooks *Hooks) stack() []*hookRun {
+	all := running()
+
+	stack := all[:0]
+	for _, run := range all {
+		if run.group == hooks.dispatch.group {
+			stack = append(stack, run)
+		}
+	}
+	return stack
+}
+
+// innermost returns the index of the innermost run of a hook of the registry
+// in stack, or -1 if there is none.
+func (hooks *Hooks) innermost(stack []*hookRun) int {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].kind == hooks.kind {
+			return i
+		}
+	}
+	return -1
+}
+
+// Go gives no cheap way to tell which goroutine is running, so running hooks
+// are not kept per goroutine. Instead, a hook calls its callbacks below a few
+// frames of the marker functions below, which encode the id of its runGroup
+// and the index of the slot describing it in the slots of the group, and the
+// functions asking for the running hooks, such as CurrentAction, decode the
+// markers found in the frames of their goroutine. A hook nobody asks about
+// only costs a slot taken from its group and a few calls.
+//
+// The goroutines started for the callbacks of a parallel action or running
+// out of time are given the slots of their caller, as the parents of their
+// own. Those slots are shared: once their hook returns, they report nothing
+// and are replaced by new ones instead of being reused, as a callback which
+// timed out may still be running.
+
+// work is done below the markers of a slot: the callbacks of a hook run by r,
+// or the callback called by c.
+type work struct {
+	r *runner
+	c *invocation
+}
+
+func (w work) do() {
+	if w.r != nil {
+		w.r.execute()
+		return
+	}
+	w.c.execute()
+}
+
+// mark does work below frames encoding the index of a slot and the id of its
+// group: a frame of mark, the digits of the index, a frame of markGroup and
+// the digits of the id, the most significant innermost. Each digit is a frame
+// of the function of its value, and zero has no digits.
+//
+//go:noinline
+func mark(group int, index int, w work) {
+	markDigits(group, index, w)
+}
+
+//go:noinline
+func markGroup(group int, index int, w work) {
+	markDigits(group, index, w)
+}
+
+// markDigits adds the frame of the next digit of n, or the frame of
+// markGroup once the index has no more digits, and then does the work once
+// the id has no more digits either. group is -1 while the id is marked.
+func markDigits(group int, n int, w work) {
+	if n == 0 {
+		if group < 0 {
+			w.do()
+			return
+		}
+		markGroup(-1, group, w)
+		return
+	}
+
+	switch next := n >> 4; n & 0xf {
+	case 0x0:
+		mark0(group, next, w)
+	case 0x1:
+		mark1(group, next, w)
+	case 0x2:
+		mark2(group, next, w)
+	case 0x3:
+		mark3(group, next, w)
+	case 0x4:
+		mark4(group, next, w)
+	case 0x5:
+		mark5(group, next, w)
+	case 0x6:
+		mark6(group, next, w)
+	case 0x7:
+		mark7(group, next, w)
+	case 0x8:
+		mark8(group, next, w)
+	case 0x9:
+		mark9(group, next, w)
+	case 0xa:
+		markA(group, next, w)
+	case 0xb:
+		markB(group, next, w)
+	case 0xc:
+		markC(group, next, w)
+	case 0xd:
+		markD(group, next, w)
+	case 0xe:
+		markE(group, next, w)
+	default:
+		markF(group, next, w)
+	}
+}
+
+//go:noinline
+func mark0(group int, n int, w work) { markDigits(group, n, w) }
+
+//go:noinline
+func mark1(group int, n int, w work) { markDigits(group, n, w) }
+
+//go:noinline
+func mark2(group int, n int, w work) { markDigits(group, n, w) }
+
+//go:noinline
+func mark3(group int, n int, w work) { markDigits(group, n, w) }
+
+//go:noinline
+func mark4(group int, n int, w work) { markDigits(group, n, w) }
+
+//go:noinline
+func mark5(group int, n int, w work) { markDigits(group, n, w) }
+
+//go:noinline
+func mark6(group int, n int, w work) { markDigits(group, n, w) }
+
+//go:noinline
+func mark7(group int, n int, w work) { markDigits(group, n, w) }
+
+//go:noinline
+func mark8(group int, n int, w work) { markDigits(group, n, w) }
+
+//go:noinline
+func mark9(group int, n int, w work) { markDigits(group, n, w) }
+
+//go:noinline
+func markA(group int, n int, w work) { markDigits(group, n, w) }
+
+//go:noinline
+func markB(group int, n int, w work) { markDigits(group, n, w) }
+
+//go:noinline
+func markC(group int, n int, w work) { markDigits(group, n, w) }
+
+//go:noinline
+func markD(group int, n int, w work) { markDigits(group, n, w) }
+
+//go:noinline
+func markE(group int, n int, w work) { markDigits(group, n, w) }
+
+//go:noinline
+func markF(group int, n int, w work) { markDigits(group, n, w) }
+
+// Marker kinds, other than the value of a digit.
+const (
+	markerSlot  = -1
+	markerGroup = -2
+)
+
+// markers maps the entry of each marker function to the digit it encodes, or
+// to its kind. The frames are told apart by their entry rather than their
+// name, since the functions inlined into the markers share their name.
+var markers map[uintptr]int
+
+func init() {
+	markers = map[uintptr]int{
+		funcEntry(mark):      markerSlot,
+		funcEntry(markGroup): markerGroup,
+	}
+	digits := []func(int, int, work){
+		mark0, mark1, mark2, mark3, mark4, mark5, mark6, mark7,
+		mark8, mark9, markA, markB, markC, markD, markE, markF,
+	}
+	for digit, f := range digits {
+		markers[funcEntry(f)] = digit
+	}
+}
+
+// funcEntry returns the entry of the function f.
+func funcEntry(f interface{}) uintptr {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Entry()
+}
+
+// runGroup holds the slots of the hooks run by the actions and filters of a
+// Core. Its id marks its slots, until it is no longer used and its id is
+// given to another group.
+type runGroup struct {
+	id    int
+	slots *slotTable
+}
+
+// slotTable holds the slots of a runGroup, by index. The free slots are kept
+// in a pool, and those the pool drops are found again by looking for an
+// unused slot before adding one. The last slot released is kept apart, still
+// marked as used, as most hooks do not run inside another one.
+type slotTable struct {
+	id int
+	// mu is held to add or replace slots.
+	mu    sync.Mutex
+	slots atomic.Pointer[[]*slot]
+	spare atomic.Pointer[slot]
+	free  sync.Pool
+}
+
+// groups holds the slot table of each runGroup in use, by id.
+var groups struct {
+	mu     sync.Mutex
+	tables atomic.Pointer[[]*slotTable]
+	// free holds the ids given back by the groups no longer used.
+	free []int
+}
+
+// newRunGroup returns a runGroup with the first free id. The id is given
+// back once the group is no longer used, which a slot in use prevents.
+func newRunGroup() *runGroup {
+	groups.mu.Lock()
+	defer groups.mu.Unlock()
+
+	var tables []*slotTable
+	if t := groups.tables.Load(); t != nil {
+		tables = *t
+	}
+
+	id := len(tables)
+	if n := len(groups.free); n > 0 {
+		id = groups.free[n-1]
+		groups.free = groups.free[:n-1]
+	}
+
+	g := &runGroup{id: id, slots: &slotTable{id: id}}
+	g.slots.slots.Store(&[]*slot{})
+
+	tables = append([]*slotTable(nil), tables...)
+	if id == len(tables) {
+		tables = append(tables, g.slots)
+	}
+	tables[id] = g.slots
+	groups.tables.Store(&tables)
+
+	runtime.SetFinalizer(g, (*runGroup).free)
+	return g
+}
+
+// free gives back the id of a group no longer used.
+func (g *runGroup) free() {
+	groups.mu.Lock()
+	defer groups.mu.Unlock()
+
+	tables := append([]*slotTable(nil), *groups.tables.Load()...)
+	tables[g.id] = nil
+	groups.tables.Store(&tables)
+	groups.free = append(groups.free, g.id)
+}
+
+// slot describes the hooks marked by its index.
+type slot struct {
+	index int
+	table *slotTable
+	// used is set while the slot is in use.
+	used atomic.Bool
+	// group is set while the slot is in use, keeping its id.
+	group *runGroup
+	// parents holds the slots of the hooks running around the goroutine
+	// started for a callback, outermost first, which are marked in its
+	// caller's frames.
+	parents atomic.Pointer[[]*slot]
+	// run describes the hook running below the markers of the slot, if
+	// hasRun is set.
+	run    hookRun
+	hasRun bool
+	// shared is set once the slot is given to another goroutine, and ended
+	// once a shared slot is released.
+	shared atomic.Bool
+	ended  atomic.Bool
+}
+
+// acquire returns a free slot of the group. When hookName is not empty, the
+// slot describes a run of it by hooks.
+func (g *runGroup) acquire(hooks *Hooks, hookName string) *slot {
+	s := g.slots.freeSlot()
+	s.group = g
+	if hookName != "" {
+		s.hasRun = true
+		s.run.name = hookName
+		s.run.kind = hooks.kind
+		s.run.group = g
+		if s.run.stopped.Load() {
+			s.run.stopped.Store(false)
+		}
+	}
+	return s
+}
+
+// freeSlot takes a free slot.
+func (t *slotTable) freeSlot() *slot {
+	if s := t.spare.Swap(nil); s != nil {
+		return s
+	}
+
+	for {
+		s, _ := t.free.Get().(*slot)
+		if s == nil {
+			break
+		}
+		if s.used.CompareAndSwap(false, true) {
+			return s
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	slots := *t.slots.Load()
+	for _, s := range slots {
+		if s.used.CompareAndSwap(false, true) {
+			return s
+		}
+	}
+
+	s := &slot{index: len(slots), table: t}
+	s.used.Store(true)
+	slots = append(slots[:len(slots):len(slots)], s)
+	t.slots.Store(&slots)
+	return s
+}
+
+// mark does work below the markers of the slot.
+func (s *slot) mark(w work) {
+	mark(s.table.id, s.index, w)
+}
+
+// share returns the slots marked in the frames of the calling goroutine,
+// which is the one using them, once they are shared. They are given as
+// parents to the goroutines started for callbacks.
+func share() *[]*slot {
+	parents := marked()
+	for _, p := range parents {
+		p.shared.Store(true)
+	}
+	return &parents
+}
+
+// at records that the run of slot s is calling the handler at index i of
+// snapshot.
+func (s *slot) at(snapshot *[]Handler, i int) {
+	run := &s.run
+	if !s.shared.Load() {
+		if run.handlers != snapshot {
+			run.handlers = snapshot
+		}
+		run.index = int64(i)
+		return
+	}
+
+	if snapshot != run.handlers && snapshot != run.moved.Load() {
+		run.moved.Store(snapshot)
+	}
+	atomic.StoreInt64(&run.index, int64(i))
+}
+
+// detach stops the goroutine started for a callback from seeing the parents
+// shared with slot s, unless s was released and taken again meanwhile.
+func (s *slot) detach(parents *[]*slot) {
+	s.parents.CompareAndSwap(parents, nil)
+}
+
+// release frees the slot once the work marked with it is done. A shared slot
+// is replaced by a new one, and reports nothing from then on.
+func (s *slot) release() {
+	if s.shared.Load() {
+		s.ended.Store(true)
+		s.parents.Store(nil)
+		s.table.replace(s)
+		return
+	}
+
+	if s.parents.Load() != nil {
+		s.parents.Store(nil)
+	}
+	// The run keeps nothing the group would be kept by, so that its id can
+	// be given back.
+	if s.hasRun {
+		s.hasRun = false
+		s.run.name = ""
+		s.run.group = nil
+		s.run.handlers = nil
+	}
+	s.group = nil
+	if s.table.spare.CompareAndSwap(nil, s) {
+		return
+	}
+	s.used.Store(false)
+	s.table.free.Put(s)
+}
+
+// replace puts a new slot in place of s.
+func (t *slotTable) replace(s *slot) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	slots := append([]*slot(nil), *t.slots.Load()...)
+	n := &slot{index: s.index, table: t}
+	slots[s.index] = n
+	t.slots.Store(&slots)
+	t.free.Put(n)
+}
+
+// runs appends the hooks described by s and its parents which have not
+// ended to stack.
+func (s *slot) runs(stack []*hookRun) []*hookRun {
+	if parents := s.parents.Load(); parents != nil {
+		for _, p := range *parents {
+			if !p.ended.Load() {
+				stack = p.runs(stack)
+			}
+		}
+	}
+	if s.hasRun {
+		stack = append(stack, &s.run)
+	}
+	return stack
+}
+
+// marked returns the slots marked in the frames of the calling goroutine,
+// outermost first.
+func marked() []*slot {
+	pcs := make([]uintptr, 64)
+	for {
+		n := runtime.Callers(2, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, 2*len(pcs))
+	}
+
+	tables := groups.tables.Load()
+	if tables == nil {
+		return nil
+	}
+
+	// The markers are found innermost first. The slots cannot be released
+	// meanwhile, since their hooks are running in this goroutine.
+	var found []*slot
+	n, group := 0, 0
+	frames := runtime.CallersFrames(pcs)
+	for more := true; more; {
+		var frame runtime.Frame
+		frame, more = frames.Next()
+
+		digit, ok := markers[frame.Entry]
+		switch {
+		case !ok || frame.Func == nil:
+		case digit == markerGroup:
+			group, n = n, 0
+		case digit == markerSlot:
+			slots := *(*tables)[group].slots.Load()
+			found = append(found, slots[n])
+			n = 0
+		default:
+			n = n<<4 | digit
+		}
+	}
+
+	for i, j := 0, len(found)-1; i < j; i, j = i+1, j-1 {
+		found[i], found[j] = found[j], found[i]
+	}
+	return found
+}
+
+// running returns the hooks of every Core running in the calling goroutine,
+// outermost first.
+func running() []*hookRun {
+	var stack []*hookRun
+	for _, s := range marked() {
+		stack = s.runs(stack)
+	}
+	return stack
+}
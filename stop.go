@@ -0,0 +1,48 @@
+package hooks
+
+import "errors"
+
+// stopped wraps the value returned by a callback that stops its hook.
+type stopped struct {
+	value interface{}
+}
+
+// Stop is returned by a callback to stop its hook: the remaining callbacks
+// are skipped and, for a filter, value becomes the final value of the chain.
+//
+//	h.AddFilter("the_content", "vendor/cache/content", func(i ...interface{}) interface{} {
+//		if cached, ok := cache[i[0].(string)]; ok {
+//			return hooks.Stop(cached)
+//		}
+//		return i[0]
+//	}, 1)
+func Stop(value interface{}) interface{} {
+	return stopped{value: value}
+}
+
+// StopAction stops the innermost action running in the calling goroutine
+// once the callback calling it returns.
+func (e *Engine) StopAction() error {
+	return stopHook(e.actions)
+}
+
+// StopFilter stops the innermost filter running in the calling goroutine
+// once the callback calling it returns.
+func (e *Engine) StopFilter() error {
+	return stopHook(e.filters)
+}
+
+// stopHook stops the currently running hook once the callback calling it
+// returns. It returns an error if no hook is currently running.
+func stopHook(hooks *Hooks) error {
+	stack := hooks.stack()
+
+	i := hooks.innermost(stack)
+	if i < 0 {
+		return errors.New("no currently running hook")
+	}
+
+	stack[i].stopped.Store(true)
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package hooks
+
+import "context"
+
+// SubscribeAction adds a callback to an action and returns a Subscription to
+// manage that single callback.
+func (e *Engine) SubscribeAction(hookName string, namespace string, callback func(...interface{}) interface{}, priority int) *Subscription {
+	return e.subscribeHook(e.actions, hookName, namespace, callback, priority)
+}
+
+// SubscribeFilter adds a callback to a filter and returns a Subscription to
+// manage that single callback.
+func (e *Engine) SubscribeFilter(hookName string, namespace string, callback func(...interface{}) interface{}, priority int) *Subscription {
+	return e.subscribeHook(e.filters, hookName, namespace, callback, priority)
+}
+
+// SubscribeActionE adds a callback which can fail by returning an error to an
+// action, and returns a Subscription to manage that single callback.
+func (e *Engine) SubscribeActionE(hookName string, namespace string, callback func(context.Context, ...interface{}) (interface{}, error), priority int) *Subscription {
+	return e.subscribeHandler(e.actions, hookName, Handler{
+		Namespace:     namespace,
+		ErrorCallback: callback,
+		Priority:      priority,
+	})
+}
+
+// SubscribeFilterE adds a callback which can fail by returning an error to a
+// filter, and returns a Subscription to manage that single callback.
+func (e *Engine) SubscribeFilterE(hookName string, namespace string, callback func(context.Context, ...interface{}) (interface{}, error), priority int) *Subscription {
+	return e.subscribeHandler(e.filters, hookName, Handler{
+		Namespace:     namespace,
+		ErrorCallback: callback,
+		Priority:      priority,
+	})
+}
+
+// subscribeHook adds a hook and returns a Subscription to manage that single
+// callback.
+func (e *Engine) subscribeHook(hooks *Hooks, hookName string, namespace string, callback func(...interface{}) interface{}, priority int) *Subscription {
+	return e.subscribeHandler(hooks, hookName, Handler{
+		Namespace: namespace,
+		Callback:  callback,
+		Priority:  priority,
+	})
+}
+
+// subscribeHandler adds a handler and returns a Subscription to manage it.
+func (e *Engine) subscribeHandler(hooks *Hooks, hookName string, handler Handler) *Subscription {
+	id := e.addHandler(hooks, hookName, handler)
+
+	return &Subscription{
+		engine:    e,
+		hooks:     hooks,
+		hookName:  hookName,
+		namespace: handler.Namespace,
+		id:        id,
+	}
+}
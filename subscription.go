@@ -0,0 +1,99 @@
+package hooks
+
+// Subscription identifies a single callback added with SubscribeAction or
+// SubscribeFilter. Unlike RemoveAction and RemoveFilter, which work on every
+// callback of a namespace, it only ever affects its own callback.
+type Subscription struct {
+	engine    *Engine
+	hooks     *Hooks
+	hookName  string
+	namespace string
+	id        uint64
+}
+
+// HookName returns the name of the hook the callback was added to.
+func (s *Subscription) HookName() string {
+	return s.hookName
+}
+
+// Namespace returns the namespace the callback was added with.
+func (s *Subscription) Namespace() string {
+	return s.namespace
+}
+
+// Remove removes the callback from its hook and triggers the HookRemoved
+// action. It returns false if the callback was already removed.
+func (s *Subscription) Remove() bool {
+	s.hooks.mu.Lock()
+
+	i := s.hooks.handlerIndex(s.hookName, s.id)
+	if i < 0 {
+		s.hooks.mu.Unlock()
+		return false
+	}
+
+	s.hooks.removeHandlerAt(s.hookName, i)
+	s.hooks.mu.Unlock()
+
+	if s.hookName != "HookRemoved" {
+		s.engine.DoAction("HookRemoved", s.hookName, s.namespace)
+	}
+
+	return true
+}
+
+// SetPriority moves the callback to a new priority, as if it was removed and
+// added again. It returns false if the callback was removed.
+func (s *Subscription) SetPriority(priority int) bool {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+
+	i := s.hooks.handlerIndex(s.hookName, s.id)
+	if i < 0 {
+		return false
+	}
+
+	handler := s.hooks.removeHandlerAt(s.hookName, i)
+	handler.Priority = priority
+
+	// The namespaces of the hook are the same, so the ordering constraints
+	// still hold.
+	s.hooks.insertHandler(s.hookName, handler)
+
+	return true
+}
+
+// Enable makes the callback run again after Disable. It returns false if the
+// callback was removed.
+func (s *Subscription) Enable() bool {
+	return s.setDisabled(false)
+}
+
+// Disable keeps the callback in its hook but skips it when the hook runs. It
+// returns false if the callback was removed.
+func (s *Subscription) Disable() bool {
+	return s.setDisabled(true)
+}
+
+// Enabled returns whether the callback is still added and not disabled.
+func (s *Subscription) Enabled() bool {
+	s.hooks.mu.RLock()
+	defer s.hooks.mu.RUnlock()
+
+	i := s.hooks.handlerIndex(s.hookName, s.id)
+	return i >= 0 && !s.hooks.Hooks[s.hookName].Handlers[i].disabled
+}
+
+func (s *Subscription) setDisabled(disabled bool) bool {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+
+	i := s.hooks.handlerIndex(s.hookName, s.id)
+	if i < 0 {
+		return false
+	}
+
+	s.hooks.Hooks[s.hookName].Handlers[i].disabled = disabled
+	s.hooks.publish(s.hookName)
+	return true
+}
@@ -0,0 +1,25 @@
+// Command soplugin is a plugin built by the tests of PluginManager.Load.
+package main
+
+import hooks "github.com/Golang-Hooks/Golang-Hooks"
+
+type titlePlugin struct{}
+
+func (titlePlugin) Name() string    { return "vendor/title" }
+func (titlePlugin) Version() string { return "1.2.0" }
+
+func (titlePlugin) Activate(core *hooks.Core) error {
+	core.AddFilter("the_title", "vendor/title/suffix", func(i ...interface{}) interface{} {
+		return i[0].(string) + "!"
+	}, 10)
+	return nil
+}
+
+func (titlePlugin) Deactivate(core *hooks.Core) error {
+	return nil
+}
+
+// HooksPlugin is looked up by PluginManager.Load.
+var HooksPlugin hooks.Plugin = titlePlugin{}
+
+func main() {}
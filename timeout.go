@@ -0,0 +1,210 @@
+package hooks
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+)
+
+// Timeouts limits the time the callbacks of a hook may take.
+type Timeouts struct {
+	// Callback limits the time each callback may take. Zero means no limit.
+	Callback time.Duration
+	// Budget limits the time all the callbacks of a run may take together.
+	// Zero means no limit.
+	Budget time.Duration
+	// Policy applies when a callback or the budget runs out of time.
+	Policy TimeoutPolicy
+}
+
+type namespaceTimeout struct {
+	pattern string
+	timeout time.Duration
+}
+
+// SetActionTimeout sets the Timeouts of an action.
+func (e *Engine) SetActionTimeout(hookName string, timeouts Timeouts) {
+	setTimeouts(e.actions, hookName, timeouts)
+}
+
+// SetFilterTimeout sets the Timeouts of a filter.
+func (e *Engine) SetFilterTimeout(hookName string, timeouts Timeouts) {
+	setTimeouts(e.filters, hookName, timeouts)
+}
+
+// setTimeouts sets the Timeouts of a hook. An empty hook name sets the
+// Timeouts of every hook without its own, and zero Timeouts remove them.
+func setTimeouts(hooks *Hooks, hookName string, timeouts Timeouts) {
+	hooks.configure(func(config *hookConfig) {
+		if timeouts == (Timeouts{}) {
+			delete(config.timeouts, hookName)
+			return
+		}
+
+		config.timeouts[hookName] = timeouts
+	})
+}
+
+// SetNamespaceTimeout limits the time each callback of the actions and
+// filters whose namespace matches a pattern may take, instead of the Callback
+// timeout of their hook. A zero timeout removes the limit set for the
+// pattern.
+func (e *Engine) SetNamespaceTimeout(pattern string, timeout time.Duration) {
+	for _, hooks := range []*Hooks{e.actions, e.filters} {
+		hooks.configure(func(config *hookConfig) {
+			kept := []namespaceTimeout{}
+			if timeout > 0 {
+				kept = append(kept, namespaceTimeout{pattern, timeout})
+			}
+			for _, t := range config.namespaceTimeouts {
+				if t.pattern != pattern {
+					kept = append(kept, t)
+				}
+			}
+			config.namespaceTimeouts = kept
+		})
+	}
+}
+
+// timeoutsOf returns the Timeouts of a hook.
+func (config *hookConfig) timeoutsOf(hookName string) Timeouts {
+	if len(config.timeouts) == 0 {
+		return Timeouts{}
+	}
+	if timeouts, ok := config.timeouts[hookName]; ok {
+		return timeouts
+	}
+	return config.timeouts[""]
+}
+
+// callbackTimeout returns the time a callback of a namespace may take, given
+// the Timeouts of its hook.
+func (config *hookConfig) callbackTimeout(namespace string, timeouts Timeouts) time.Duration {
+	for _, t := range config.namespaceTimeouts {
+		if matchNamespace(t.pattern, namespace) {
+			return t.timeout
+		}
+	}
+	return timeouts.Callback
+}
+
+// invocation calls a callback in a goroutine started for it, below the
+// markers of a slot describing the hooks around it.
+type invocation struct {
+	ctx           context.Context
+	engine        *Engine
+	hooks         *Hooks
+	hookName      string
+	handler       Handler
+	recoverPanics bool
+	args          []interface{}
+	timeout       time.Duration
+	o             outcome
+}
+
+// execute calls the callback, as invokeTimed does when a timeout is set and
+// as invokeHandler does otherwise. It must not be inlined into the markers.
+//
+//go:noinline
+func (c *invocation) execute() {
+	if c.timeout > 0 {
+		c.o = c.engine.invokeTimed(c.ctx, c.hooks, c.hookName, c.handler, c.recoverPanics, c.args, c.timeout)
+		return
+	}
+	c.o = c.engine.invokeHandler(c.ctx, c.hookName, &c.handler, c.recoverPanics, c.args)
+}
+
+// invokeTimed is like invokeHandler, but gives up waiting for the callback
+// after timeout, in which case the outcome holds a TimeoutError and the
+// HookTimedOut action is triggered. The callback runs in its own goroutine,
+// which sees the hooks running in the caller, with a context ending after
+// timeout. A callback which does not return in time keeps running in the
+// background, and its value is dropped. It no longer sees the hooks of the
+// caller from then on, so that it cannot stop them or report them as
+// running. A panic it does not recover from is raised again in the caller
+// as a *PanicError.
+func (e *Engine) invokeTimed(ctx context.Context, hooks *Hooks, hookName string, handler Handler, recoverPanics bool, args []interface{}, timeout time.Duration) outcome {
+	// The slot of the goroutine is taken here, so that its parents can be
+	// detached from it once it runs out of time.
+	parents := share()
+	slot := hooks.dispatch.group.acquire(hooks, "")
+	slot.parents.Store(parents)
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		o        outcome
+		panicErr *PanicError
+	}
+	done := make(chan result, 1)
+
+	// A filter callback must not see the value changed by the callbacks
+	// after it.
+	args = append([]interface{}(nil), args...)
+
+	go func() {
+		defer slot.release()
+
+		// A panic which is not recovered is raised again in the caller, as a
+		// PanicError holding the stack of the callback, which is lost once
+		// this goroutine unwinds.
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{panicErr: &PanicError{
+					Hook:      hookName,
+					Namespace: handler.Namespace,
+					Priority:  handler.Priority,
+					Value:     r,
+					Stack:     debug.Stack(),
+				}}
+			}
+		}()
+
+		c := &invocation{ctx: runCtx, engine: e, hooks: hooks, hookName: hookName, handler: handler, recoverPanics: recoverPanics, args: args}
+		slot.mark(work{c: c})
+		done <- result{o: c.o}
+	}()
+
+	select {
+	case r := <-done:
+		if r.panicErr != nil {
+			panic(r.panicErr)
+		}
+		return r.o
+	case <-runCtx.Done():
+	}
+
+	slot.detach(parents)
+
+	if ctx.Err() != nil {
+		return outcome{err: &HookError{
+			Hook:      hookName,
+			Namespace: handler.Namespace,
+			Priority:  handler.Priority,
+			Err:       ctx.Err(),
+		}}
+	}
+
+	err := &TimeoutError{
+		Hook:      hookName,
+		Namespace: handler.Namespace,
+		Priority:  handler.Priority,
+		Timeout:   timeout,
+	}
+	if hookName != "HookTimedOut" {
+		e.DoAction("HookTimedOut", hookName, handler.Namespace, handler.Priority, err)
+	}
+
+	return outcome{err: err, timedOut: true}
+}
+
+// budgetExhausted reports that the budget of a hook ran out before all its
+// callbacks could run, triggering the HookTimedOut action.
+func (e *Engine) budgetExhausted(hookName string, budget time.Duration) *TimeoutError {
+	err := &TimeoutError{Hook: hookName, Timeout: budget}
+	if hookName != "HookTimedOut" {
+		e.DoAction("HookTimedOut", hookName, "", 0, err)
+	}
+	return err
+}
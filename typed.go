@@ -0,0 +1,156 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+)
+
+// Filter is a type-safe handle on a filter whose value is of type T. It is
+// backed by the filters of an Interface, so callbacks added through a Filter and
+// through AddFilter run in the same chain, in priority order.
+type Filter[T any] struct {
+	hooks Interface
+	name  string
+}
+
+// NewFilter returns a Filter for the hook hookName of hooks.
+func NewFilter[T any](hooks Interface, hookName string) Filter[T] {
+	return Filter[T]{hooks: hooks, name: hookName}
+}
+
+// Name returns the name of the hook.
+func (f Filter[T]) Name() string {
+	return f.name
+}
+
+// Add adds a callback to the filter. When an untyped callback earlier in the
+// chain returns something other than a T, the callback is skipped and the
+// value is passed on unchanged.
+func (f Filter[T]) Add(namespace string, callback func(T) T, priority int) {
+	f.hooks.AddFilter(f.name, namespace, func(args ...interface{}) interface{} {
+		if v, ok := args[0].(T); ok {
+			return callback(v)
+		}
+		return args[0]
+	}, priority)
+}
+
+// AddE adds a callback that receives the context of the dispatch and can
+// fail by returning an error, like AddFilterE.
+func (f Filter[T]) AddE(namespace string, callback func(context.Context, T) (T, error), priority int) {
+	f.hooks.AddFilterE(f.name, namespace, func(ctx context.Context, args ...interface{}) (interface{}, error) {
+		if v, ok := args[0].(T); ok {
+			return callback(ctx, v)
+		}
+		return args[0], nil
+	}, priority)
+}
+
+// Apply runs the filter on value and returns the result. The zero value of T
+// is returned if the chain did not produce a T.
+func (f Filter[T]) Apply(value T) T {
+	v, _ := f.ApplyContext(context.Background(), value)
+	return v
+}
+
+// ApplyContext runs the filter on value like ApplyFiltersContext. Besides the
+// errors of the chain, an error is returned if the chain did not produce a T.
+func (f Filter[T]) ApplyContext(ctx context.Context, value T) (T, error) {
+	r, err := f.hooks.ApplyFiltersContext(ctx, f.name, value)
+
+	v, ok := r.(T)
+	if !ok {
+		typeErr := fmt.Errorf("hook %s: filtered value of type %T is not a %T", f.name, r, v)
+		if err == nil {
+			return v, typeErr
+		}
+		return v, joinErrors([]error{err, typeErr})
+	}
+
+	return v, err
+}
+
+// Remove removes the callbacks of the filter added with namespace.
+func (f Filter[T]) Remove(namespace string) int {
+	return f.hooks.RemoveFilter(f.name, namespace)
+}
+
+// Has returns whether the filter exists.
+func (f Filter[T]) Has() bool {
+	return f.hooks.HasFilter(f.name)
+}
+
+// Did returns the number of times the filter has been applied.
+func (f Filter[T]) Did() int {
+	return f.hooks.DidFilter(f.name)
+}
+
+// Action is a type-safe handle on an action whose argument is of type A. Use
+// a struct for actions with several arguments. It is backed by the actions of
+// an Interface, so callbacks added through an Action and through AddAction run in
+// the same chain, in priority order.
+type Action[A any] struct {
+	hooks Interface
+	name  string
+}
+
+// NewAction returns an Action for the hook hookName of hooks.
+func NewAction[A any](hooks Interface, hookName string) Action[A] {
+	return Action[A]{hooks: hooks, name: hookName}
+}
+
+// Name returns the name of the hook.
+func (a Action[A]) Name() string {
+	return a.name
+}
+
+// Add adds a callback to the action. The callback is skipped when the action
+// is run by an untyped caller with an argument that is not an A.
+func (a Action[A]) Add(namespace string, callback func(A), priority int) {
+	a.hooks.AddAction(a.name, namespace, func(args ...interface{}) interface{} {
+		if len(args) > 0 {
+			if v, ok := args[0].(A); ok {
+				callback(v)
+			}
+		}
+		return nil
+	}, priority)
+}
+
+// AddE adds a callback that receives the context of the dispatch and can
+// fail by returning an error, like AddActionE.
+func (a Action[A]) AddE(namespace string, callback func(context.Context, A) error, priority int) {
+	a.hooks.AddActionE(a.name, namespace, func(ctx context.Context, args ...interface{}) (interface{}, error) {
+		if len(args) > 0 {
+			if v, ok := args[0].(A); ok {
+				return nil, callback(ctx, v)
+			}
+		}
+		return nil, nil
+	}, priority)
+}
+
+// Do runs the action with arg.
+func (a Action[A]) Do(arg A) {
+	a.hooks.DoAction(a.name, arg)
+}
+
+// DoContext runs the action with arg like DoActionContext.
+func (a Action[A]) DoContext(ctx context.Context, arg A) error {
+	return a.hooks.DoActionContext(ctx, a.name, arg)
+}
+
+// Remove removes the callbacks of the action added with namespace.
+func (a Action[A]) Remove(namespace string) int {
+	return a.hooks.RemoveAction(a.name, namespace)
+}
+
+// Has returns whether the action exists.
+func (a Action[A]) Has() bool {
+	return a.hooks.HasAction(a.name)
+}
+
+// Did returns the number of times the action has been run.
+func (a Action[A]) Did() int {
+	return a.hooks.DidAction(a.name)
+}
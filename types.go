@@ -1,43 +1,196 @@
 package hooks
 
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Kind tells actions and filters apart.
+type Kind string
+
+const (
+	KindAction Kind = "action"
+	KindFilter Kind = "filter"
+)
+
 type Hooks struct {
-	Hooks   map[string]Handlers
+	Hooks map[string]Handlers
+
+	// Current is no longer updated.
+	//
+	// Deprecated: the running hooks are tracked for each goroutine. Use
+	// Core.CurrentAction, Core.CurrentFilter or Core.Stack instead.
 	Current []*HookInfo
+
+	// kind is the kind of the hooks of the registry.
+	kind Kind
+
+	// patterns holds the handlers added with a hook pattern, which are copied
+	// into the entry of every hook whose name matches.
+	patterns []Handler
+
+	// breakers holds the circuit breakers of the registry.
+	breakers *breakers
+
+	// nextID is the id given to the last handler added.
+	nextID uint64
+
+	// dispatch holds what running hooks read without locking: a snapshot of
+	// the handlers of each hook, published whenever they change, the
+	// settings of the hooks, and the group of its running hooks.
+	dispatch *dispatch
+
+	// mu guards Hooks, patterns and nextID, and serializes the changes
+	// published to dispatch. It is a pointer so that copies of Hooks (such as
+	// Core.Actions) keep sharing the same lock.
+	mu *sync.RWMutex
 }
 
 type Handler struct {
 	Namespace string
 	Callback  func(...interface{}) interface{}
 	Priority  int
+
+	// ContextCallback is set instead of Callback for handlers added with
+	// AddActionContext or AddFilterContext.
+	ContextCallback func(context.Context, ...interface{}) interface{}
+
+	// ErrorCallback is set instead of Callback for handlers added with
+	// AddActionE or AddFilterE.
+	ErrorCallback func(context.Context, ...interface{}) (interface{}, error)
+
+	// Order is set for handlers added with AddActionOrdered or
+	// AddFilterOrdered.
+	Order Order
+
+	// Pattern is the hook pattern the handler was added with by
+	// AddActionPattern or AddFilterPattern.
+	Pattern string
+
+	// Owner is the name of the Plugin that added the handler, if any.
+	Owner string
+
+	// id identifies the handler within its Hooks registry.
+	id uint64
+	// source is where the handler was added, as "file:line".
+	source string
+	// disabled handlers are skipped when their hook runs.
+	disabled bool
+	// suspended handlers are skipped too. It is kept apart from disabled so
+	// that resuming a namespace does not enable callbacks disabled through
+	// their Subscription.
+	suspended bool
 }
 
 type Handlers struct {
 	Handlers []Handler
-	Runs     int
+	// Deprecated: Runs is no longer updated, as hooks run without locking
+	// the registry. Use DidAction or DidFilter instead.
+	Runs int
 }
 
 type HookInfo struct {
-	Name         string
+	Name string
+	// Kind tells whether the hook is an action or a filter.
+	Kind         Kind
 	CurrentIndex int
+	// Stopped is set once a callback stopped the hook, which will not run any
+	// more callbacks.
+	Stopped bool
+
+	// Depth is the number of actions and filters running around the hook in
+	// the same goroutine, 0 for a hook which is not nested.
+	Depth int
+	// Parent is the name of the action or filter whose callback ran the hook,
+	// if any.
+	Parent string
+
+	// Namespace and Priority are those of the callback of the hook running.
+	Namespace string
+	Priority  int
 }
 
+// Core is the field-based API of a hooks system, kept for compatibility. Its
+// functions are bound to the methods of an Interface, usually an Engine.
 type Core struct {
-	AddAction    func(string, string, func(...interface{}) interface{}, int)
-	DoAction     func(string, ...interface{}) interface{}
-	AddFilter    func(string, string, func(...interface{}) interface{}, int)
-	ApplyFilters func(string, ...interface{}) interface{}
-	CurrentAction func() (HookInfo, error)
-	CurrentFilter func() (HookInfo, error)
-	DidAction func(string) (int)
-	DidFilter func(string) (int)
-	DoingAction func(string) (bool)
-	DoingFilter func(string) (bool)
-	HasAction func(string) (bool)
-	HasFilter func(string) (bool)
-	RemoveAction func(string, string) (int)
-	RemoveFilter func(string, string) (int)
-	RemoveAllActions func(string, string) (int)
-	RemoveAllFilters func(string, string) (int)
+	AddAction        func(string, string, func(...interface{}) interface{}, int)
+	DoAction         func(string, ...interface{}) interface{}
+	AddFilter        func(string, string, func(...interface{}) interface{}, int)
+	ApplyFilters     func(string, ...interface{}) interface{}
+	CurrentAction    func() (HookInfo, error)
+	CurrentFilter    func() (HookInfo, error)
+	DidAction        func(string) int
+	DidFilter        func(string) int
+	DoingAction      func(string) bool
+	DoingFilter      func(string) bool
+	HasAction        func(string) bool
+	HasFilter        func(string) bool
+	RemoveAction     func(string, string) int
+	RemoveFilter     func(string, string) int
+	RemoveAllActions func(string, string) int
+	RemoveAllFilters func(string, string) int
+
+	AddActionContext    func(string, string, func(context.Context, ...interface{}) interface{}, int)
+	AddFilterContext    func(string, string, func(context.Context, ...interface{}) interface{}, int)
+	DoActionContext     func(context.Context, string, ...interface{}) error
+	ApplyFiltersContext func(context.Context, string, ...interface{}) (interface{}, error)
+
+	AddActionE           func(string, string, func(context.Context, ...interface{}) (interface{}, error), int)
+	AddFilterE           func(string, string, func(context.Context, ...interface{}) (interface{}, error), int)
+	DoActionE            func(string, ...interface{}) error
+	ApplyFiltersE        func(string, ...interface{}) (interface{}, error)
+	SetActionErrorPolicy func(string, ErrorPolicy)
+	SetFilterErrorPolicy func(string, ErrorPolicy)
+	SetActionPanicPolicy func(string, PanicPolicy)
+	SetFilterPanicPolicy func(string, PanicPolicy)
+
+	SubscribeAction  func(string, string, func(...interface{}) interface{}, int) *Subscription
+	SubscribeFilter  func(string, string, func(...interface{}) interface{}, int) *Subscription
+	SubscribeActionE func(string, string, func(context.Context, ...interface{}) (interface{}, error), int) *Subscription
+	SubscribeFilterE func(string, string, func(context.Context, ...interface{}) (interface{}, error), int) *Subscription
+
+	StopAction func() error
+	StopFilter func() error
+
+	AddActionOrdered func(string, string, func(...interface{}) interface{}, int, Order) error
+	AddFilterOrdered func(string, string, func(...interface{}) interface{}, int, Order) error
+
+	AddActionPattern    func(string, string, func(...interface{}) interface{}, int)
+	AddFilterPattern    func(string, string, func(...interface{}) interface{}, int)
+	RemoveActionPattern func(string, string) int
+	RemoveFilterPattern func(string, string) int
+
+	RemoveNamespace  func(string) int
+	SuspendNamespace func(string) int
+	ResumeNamespace  func(string) int
+
+	SetActionParallel func(string, int)
+	DoActionCollect   func(context.Context, string, ...interface{}) ([]interface{}, error)
+
+	SetActionTimeout    func(string, Timeouts)
+	SetFilterTimeout    func(string, Timeouts)
+	SetNamespaceTimeout func(string, time.Duration)
+
+	SetActionBreaker func(string, BreakerOptions)
+	SetFilterBreaker func(string, BreakerOptions)
+	ActionBreakers   func() []BreakerInfo
+	FilterBreakers   func() []BreakerInfo
+
+	Hook func(string) *Hook
+
+	ListHooks      func() []HookSummary
+	ActionHandlers func(string) []HandlerInfo
+	FilterHandlers func(string) []HandlerInfo
+	Stack          func() []HookInfo
+
+	// Deprecated: Actions is a copy of the registry of actions taken when
+	// the Core was created. Use ListHooks, ActionHandlers and Stack instead.
 	Actions Hooks
+	// Deprecated: Filters is a copy of the registry of filters taken when
+	// the Core was created. Use ListHooks, FilterHandlers and Stack instead.
 	Filters Hooks
+
+	// engine is the Engine the functions above were bound to, if any.
+	engine *Engine
 }